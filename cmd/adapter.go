@@ -0,0 +1,374 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var fuzzerName string
+
+// FuzzerAdapter teaches osiris-lite how to run and read one fuzzer. Echidna,
+// Medusa and Foundry are built in; more can be described in the fuzzers
+// config section.
+type FuzzerAdapter interface {
+	// Name identifies the adapter in config, run --fuzzer and job records
+	Name() string
+	// Spec declares the command template, paths and signals of the fuzzer
+	Spec() AdapterSpec
+	// Matches reports whether a command runs this fuzzer
+	Matches(command string) bool
+	// Prepare reads the fuzzer's config from the synced tree at root and
+	// applies run's override flags to the command or the job environment
+	Prepare(root, command string, env jobEnv) (*FuzzerSettings, string, error)
+	// Parse reads the fuzzer's output, whole or just the progress lines,
+	// into results
+	Parse(r io.Reader) (*Results, error)
+}
+
+// AdapterSpec is the declarative part of an adapter.
+type AdapterSpec struct {
+	// Command is what run --fuzzer runs; {{args}} is replaced by run's
+	// arguments and {{config}} by Config
+	Command string `mapstructure:"command"`
+	Config  string `mapstructure:"config"`
+
+	// Corpus and Results are directories pull <job> fetches, relative to
+	// the project
+	Corpus  string `mapstructure:"corpus"`
+	Results string `mapstructure:"results"`
+
+	// StopSignal is sent to the container by kill so the fuzzer can save
+	// its corpus and report before exiting
+	StopSignal string `mapstructure:"stop-signal"`
+
+	// Progress and Latest select the log lines status parses on the remote:
+	// every line matching Progress and the last one matching Latest. Unlike
+	// the other patterns they are POSIX extended regexes, as awk reads them
+	Progress string `mapstructure:"progress"`
+	Latest   string `mapstructure:"latest"`
+}
+
+// customSpec is a fuzzers config entry. Tests are read from output lines
+// matching Test, whose name and status groups give the test and its state.
+type customSpec struct {
+	AdapterSpec `mapstructure:",squash"`
+
+	Match    string   `mapstructure:"match"`
+	Test     string   `mapstructure:"test"`
+	Passed   []string `mapstructure:"passed"`
+	Failed   []string `mapstructure:"failed"`
+	Sequence string   `mapstructure:"sequence"`
+	Coverage string   `mapstructure:"coverage"`
+}
+
+// customAdapter runs a fuzzer described in the config.
+type customAdapter struct {
+	name     string
+	spec     AdapterSpec
+	match    *regexp.Regexp
+	test     *regexp.Regexp
+	sequence *regexp.Regexp
+	coverage *regexp.Regexp
+	passed   map[string]bool
+	failed   map[string]bool
+}
+
+// builtinAdapters are checked after custom ones, in this order.
+var builtinAdapters = []FuzzerAdapter{medusaAdapter{}, echidnaAdapter{}, foundryAdapter{}}
+
+// loadAdapters returns the custom adapters from the fuzzers config section,
+// sorted by name, followed by the built-in ones.
+func loadAdapters() ([]FuzzerAdapter, error) {
+	raw := make(map[string]customSpec)
+	if err := viper.UnmarshalKey("fuzzers", &raw); err != nil {
+		return nil, fmt.Errorf("invalid fuzzers config: %w", err)
+	}
+
+	names := make([]string, 0, len(raw))
+	for name := range raw {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var registry []FuzzerAdapter
+	for _, name := range names {
+		for _, builtin := range builtinAdapters {
+			if builtin.Name() == name {
+				return nil, fmt.Errorf("fuzzers.%s: %s is a built-in adapter", name, name)
+			}
+		}
+		adapter, err := newCustomAdapter(name, raw[name])
+		if err != nil {
+			return nil, fmt.Errorf("fuzzers.%s: %w", name, err)
+		}
+		registry = append(registry, adapter)
+	}
+	return append(registry, builtinAdapters...), nil
+}
+
+func newCustomAdapter(name string, spec customSpec) (*customAdapter, error) {
+	if spec.Match == "" || spec.Test == "" || len(spec.Passed) == 0 {
+		return nil, fmt.Errorf("match, test and passed are required")
+	}
+	a := &customAdapter{name: name, spec: spec.AdapterSpec, passed: make(map[string]bool), failed: make(map[string]bool)}
+
+	var err error
+	if a.match, err = regexp.Compile(spec.Match); err != nil {
+		return nil, fmt.Errorf("invalid match: %w", err)
+	}
+	if a.test, err = regexp.Compile(spec.Test); err != nil {
+		return nil, fmt.Errorf("invalid test: %w", err)
+	}
+	if a.test.SubexpIndex("name") < 0 || a.test.SubexpIndex("status") < 0 {
+		return nil, fmt.Errorf("test needs (?P<name>...) and (?P<status>...) groups")
+	}
+	if spec.Sequence != "" {
+		if a.sequence, err = regexp.Compile(spec.Sequence); err != nil {
+			return nil, fmt.Errorf("invalid sequence: %w", err)
+		}
+	}
+	if spec.Coverage != "" {
+		if a.coverage, err = regexp.Compile(spec.Coverage); err != nil {
+			return nil, fmt.Errorf("invalid coverage: %w", err)
+		}
+		if a.coverage.SubexpIndex("coverage") < 0 {
+			return nil, fmt.Errorf("coverage needs a (?P<coverage>...) group")
+		}
+	}
+	for _, p := range []struct{ key, pattern string }{{"progress", spec.Progress}, {"latest", spec.Latest}} {
+		if _, err := regexp.CompilePOSIX(p.pattern); err != nil {
+			return nil, fmt.Errorf("invalid %s (a POSIX extended regex, without \\d, \\s or named groups): %w", p.key, err)
+		}
+	}
+	for _, s := range spec.Passed {
+		a.passed[s] = true
+	}
+	for _, s := range spec.Failed {
+		a.failed[s] = true
+	}
+	return a, nil
+}
+
+func (a *customAdapter) Name() string                { return a.name }
+func (a *customAdapter) Spec() AdapterSpec           { return a.spec }
+func (a *customAdapter) Matches(command string) bool { return a.match.MatchString(command) }
+
+func (a *customAdapter) Prepare(root, command string, env jobEnv) (*FuzzerSettings, string, error) {
+	if err := checkOverrides(a.name); err != nil {
+		return nil, "", err
+	}
+	return newSettings(a), command, nil
+}
+
+// Parse reads test outcomes from lines matching the test regex. Lines
+// matching the sequence regex right after a failed test are its call
+// sequence; status values in neither passed nor failed are errors, unless
+// failed is empty.
+func (a *customAdapter) Parse(r io.Reader) (*Results, error) {
+	results := &Results{Fuzzer: a.name}
+	if a.coverage != nil {
+		results.CoverageUnit = "coverage"
+	}
+	index := make(map[string]int)
+	var current *TestResult
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		line := ansiEscape.ReplaceAllString(scanner.Text(), "")
+
+		if m := a.test.FindStringSubmatch(line); m != nil {
+			name, state := m[a.test.SubexpIndex("name")], m[a.test.SubexpIndex("status")]
+			status := "error"
+			switch {
+			case a.passed[state]:
+				status = "passed"
+			case a.failed[state] || len(a.failed) == 0:
+				status = "failed"
+			}
+			i, seen := index[name]
+			if !seen {
+				i = len(results.Tests)
+				index[name] = i
+				results.Tests = append(results.Tests, TestResult{Name: name})
+			}
+			results.Tests[i] = TestResult{Name: name, Status: status}
+			current = nil
+			if status != "passed" {
+				current = &results.Tests[i]
+			}
+			continue
+		}
+
+		if current != nil && a.sequence != nil {
+			if m := a.sequence.FindStringSubmatch(line); m != nil {
+				call := m[0]
+				if i := a.sequence.SubexpIndex("call"); i >= 0 {
+					call = m[i]
+				}
+				current.Sequence = append(current.Sequence, strings.TrimSpace(call))
+				continue
+			}
+		}
+		current = nil
+
+		if a.coverage != nil {
+			if m := a.coverage.FindStringSubmatch(line); m != nil {
+				var p CoveragePoint
+				fmt.Sscan(m[a.coverage.SubexpIndex("coverage")], &p.Coverage)
+				if i := a.coverage.SubexpIndex("corpus"); i >= 0 {
+					fmt.Sscan(m[i], &p.Corpus)
+				}
+				results.observe(p)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s output: %w", a.name, err)
+	}
+
+	results.Total = len(results.Tests)
+	for _, t := range results.Tests {
+		if t.Status == "failed" {
+			results.Broken++
+		}
+	}
+	return results, nil
+}
+
+// newSettings starts a job's fuzzer settings from the adapter's spec.
+func newSettings(a FuzzerAdapter) *FuzzerSettings {
+	spec := a.Spec()
+	return &FuzzerSettings{
+		Name:       a.Name(),
+		Config:     spec.Config,
+		Corpus:     spec.Corpus,
+		Results:    spec.Results,
+		StopSignal: spec.StopSignal,
+	}
+}
+
+// checkOverrides rejects run's fuzzer override flags that the adapter does
+// not support.
+func checkOverrides(name string, supported ...string) error {
+	given := map[string]bool{
+		"timeout":        fuzzTimeout > 0,
+		"workers":        fuzzWorkers > 0,
+		"corpus-dir":     fuzzCorpusDir != "",
+		"runs":           fuzzRuns > 0,
+		"depth":          fuzzDepth > 0,
+		"fail-on-revert": fuzzFailOnRevert != nil,
+	}
+	for _, flag := range supported {
+		delete(given, flag)
+	}
+	var unsupported []string
+	for flag, set := range given {
+		if set {
+			unsupported = append(unsupported, "--"+flag)
+		}
+	}
+	if len(unsupported) == 0 {
+		return nil
+	}
+	sort.Strings(unsupported)
+	if name == "" {
+		return fmt.Errorf("%s need a command run by a known fuzzer (see osiris-lite fuzzers)", strings.Join(unsupported, ", "))
+	}
+	return fmt.Errorf("%s not supported by the %s adapter", strings.Join(unsupported, ", "), name)
+}
+
+// lookupAdapter finds an adapter by name.
+func lookupAdapter(registry []FuzzerAdapter, name string) (FuzzerAdapter, error) {
+	var known []string
+	for _, a := range registry {
+		if a.Name() == name {
+			return a, nil
+		}
+		known = append(known, a.Name())
+	}
+	return nil, fmt.Errorf("unknown fuzzer %q (known: %s)", name, strings.Join(known, ", "))
+}
+
+// matchAdapter returns the first adapter whose match the command satisfies.
+func matchAdapter(registry []FuzzerAdapter, command string) FuzzerAdapter {
+	for _, a := range registry {
+		if a.Matches(command) {
+			return a
+		}
+	}
+	return nil
+}
+
+// adapterFor returns the adapter of a job, by its recorded fuzzer or else by
+// its command.
+func adapterFor(registry []FuzzerAdapter, job Job) FuzzerAdapter {
+	if job.Fuzzer != nil {
+		a, _ := lookupAdapter(registry, job.Fuzzer.Name)
+		return a
+	}
+	return matchAdapter(registry, job.Command)
+}
+
+// fuzzerCommand renders an adapter's command template for run --fuzzer.
+func fuzzerCommand(a FuzzerAdapter, args []string) (string, error) {
+	spec := a.Spec()
+	if spec.Command == "" {
+		return "", fmt.Errorf("the %s adapter has no command template; pass the command instead", a.Name())
+	}
+	values := []string{"{{args}}", strings.Join(args, " "), "{{config}}", spec.Config}
+
+	// An empty placeholder takes the space before it along, leaving the rest
+	// of the template, quoted arguments included, as written
+	command := spec.Command
+	for i := 0; i < len(values); i += 2 {
+		if values[i+1] == "" {
+			command = strings.ReplaceAll(command, " "+values[i], "")
+		}
+	}
+	return strings.TrimSpace(strings.NewReplacer(values...).Replace(command)), nil
+}
+
+func newFuzzersCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "fuzzers",
+		Short: "List the fuzzer adapters, built in and from the fuzzers config",
+		Args:  cobra.NoArgs,
+		RunE:  fuzzersCommand,
+	}
+}
+
+func fuzzersCommand(cmd *cobra.Command, args []string) error {
+	registry, err := loadAdapters()
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tSOURCE\tCOMMAND\tCONFIG\tCORPUS\tRESULTS\tSTOP SIGNAL")
+	for _, a := range registry {
+		source := "config"
+		if _, custom := a.(*customAdapter); !custom {
+			source = "built-in"
+		}
+		spec := a.Spec()
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", a.Name(), source, orDash(spec.Command), orDash(spec.Config),
+			orDash(spec.Corpus), orDash(spec.Results), orDash(spec.StopSignal))
+	}
+	return w.Flush()
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,335 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+var (
+	buildFlags   BuildOptions
+	buildAll     bool
+	buildLocal   bool
+	buildTimeout time.Duration
+
+	platformPattern = regexp.MustCompile(`^[a-z0-9]+/[a-z0-9_]+(/[a-z0-9]+)?(,[a-z0-9]+/[a-z0-9_]+(/[a-z0-9]+)?)*$`)
+	targetPattern   = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.\-]*$`)
+)
+
+// BuildOptions are the `docker build` settings from the `build:` config block
+// and the matching build flags. Args, target and platform are part of the
+// image's context hash; secrets are not.
+type BuildOptions struct {
+	Args     []string `mapstructure:"args" json:"args,omitempty"`
+	Target   string   `mapstructure:"target" json:"target,omitempty"`
+	Platform string   `mapstructure:"platform" json:"platform,omitempty"`
+	Secrets  []string `mapstructure:"secrets" json:"secrets,omitempty"`
+
+	// secretEnv carries the secret values to the remote build over stdin
+	secretEnv  jobEnv
+	secretArgs []string
+}
+
+// ImageRecord is written to the remote after every build so jobs and later
+// runs can tell exactly which image a tag resolved to.
+type ImageRecord struct {
+	Ref   string       `json:"ref"`
+	ID    string       `json:"id"`
+	Build BuildOptions `json:"build"`
+	Host  string       `json:"host,omitempty"`
+	Built time.Time    `json:"built"`
+
+	// Local is set when the image was built locally and shipped
+	Local bool `json:"local,omitempty"`
+}
+
+func newBuildCommand() *cobra.Command {
+	buildCmd := &cobra.Command{
+		Use:   "build",
+		Short: "Build the Docker image on the remote without running a job",
+		Args:  cobra.NoArgs,
+		RunE:  buildCommand,
+	}
+	buildCmd.Flags().BoolVar(&rebuild, "rebuild", false, "Rebuild even if the build context is unchanged")
+	buildCmd.Flags().BoolVar(&buildAll, "all", false, "Build on every host in the hosts config in parallel")
+	buildCmd.Flags().BoolVar(&buildLocal, "local", false, "Build with the local Docker daemon and ship the image over SSH (needs zstd on both ends)")
+	buildCmd.Flags().BoolVarP(&syncYes, "yes", "y", false, "Delete remote files beyond the sync delete threshold without asking")
+	buildCmd.Flags().DurationVar(&buildTimeout, "timeout", time.Hour, "Per-host timeout for --all")
+	addBuildFlags(buildCmd.Flags())
+	return buildCmd
+}
+
+// addBuildFlags registers the docker build option flags on a command that
+// builds images.
+func addBuildFlags(flags *pflag.FlagSet) {
+	flags.StringArrayVar(&buildFlags.Args, "build-arg", nil, "Build argument as KEY=VALUE, e.g. ECHIDNA_VERSION=2.2.5 (repeatable)")
+	flags.StringVar(&buildFlags.Target, "target", "", "Build stage of a multi-stage Dockerfile")
+	flags.StringVar(&buildFlags.Platform, "platform", "", "Target platform, e.g. linux/amd64")
+	flags.StringArrayVar(&buildFlags.Secrets, "secret", nil, "Forward a build secret as id=ID,src=FILE or id=ID,env=VAR; a bare NAME forwards $NAME (repeatable)")
+}
+
+// loadBuildOptions merges the config block with flags and resolves secret
+// values locally. Flag args and secrets add to the configured ones; target and
+// platform replace them.
+func loadBuildOptions() (BuildOptions, error) {
+	var opts BuildOptions
+	if err := viper.UnmarshalKey("build", &opts); err != nil {
+		return opts, fmt.Errorf("invalid build config: %w", err)
+	}
+
+	opts.Args = append(opts.Args, buildFlags.Args...)
+	opts.Secrets = append(opts.Secrets, buildFlags.Secrets...)
+	if buildFlags.Target != "" {
+		opts.Target = buildFlags.Target
+	}
+	if buildFlags.Platform != "" {
+		opts.Platform = buildFlags.Platform
+	}
+
+	if err := opts.validate(); err != nil {
+		return opts, err
+	}
+	return opts, opts.resolveSecrets()
+}
+
+func (o BuildOptions) validate() error {
+	for _, arg := range o.Args {
+		key, _, ok := strings.Cut(arg, "=")
+		if !ok || !envKeyRegex.MatchString(key) {
+			return fmt.Errorf("invalid build arg %q: expected KEY=VALUE", arg)
+		}
+	}
+	if o.Target != "" && !targetPattern.MatchString(o.Target) {
+		return fmt.Errorf("invalid target %q", o.Target)
+	}
+	if o.Platform != "" && !platformPattern.MatchString(o.Platform) {
+		return fmt.Errorf("invalid platform %q: expected os/arch[/variant]", o.Platform)
+	}
+	return nil
+}
+
+// resolveSecrets reads every secret locally. Each value is shipped as an
+// environment variable on the build session's stdin and handed to BuildKit
+// with --secret id=ID,env=VAR, so it never touches the remote disk.
+func (o *BuildOptions) resolveSecrets() error {
+	o.secretEnv = jobEnv{values: make(map[string]string), secret: make(map[string]bool)}
+	o.secretArgs = nil
+
+	for i, spec := range o.Secrets {
+		fields := map[string]string{}
+		if !strings.Contains(spec, "=") {
+			fields["id"], fields["env"] = spec, spec
+		} else {
+			for _, part := range strings.Split(spec, ",") {
+				key, value, ok := strings.Cut(part, "=")
+				if !ok {
+					return fmt.Errorf("invalid secret %q: expected id=ID,src=FILE or id=ID,env=VAR", spec)
+				}
+				fields[key] = value
+			}
+		}
+
+		id := fields["id"]
+		if !targetPattern.MatchString(id) {
+			return fmt.Errorf("invalid secret %q: missing or invalid id", spec)
+		}
+
+		var value string
+		switch {
+		case fields["src"] != "":
+			content, err := os.ReadFile(expandPath(fields["src"]))
+			if err != nil {
+				return fmt.Errorf("failed to read secret %s: %w", id, err)
+			}
+			value = string(content)
+		case fields["env"] != "":
+			v, ok := os.LookupEnv(fields["env"])
+			if !ok {
+				return fmt.Errorf("secret %s: $%s is not set", id, fields["env"])
+			}
+			value = v
+		default:
+			return fmt.Errorf("invalid secret %q: needs src= or env=", spec)
+		}
+
+		name := fmt.Sprintf("OSIRIS_BUILD_SECRET_%d", i)
+		if err := o.secretEnv.set(name, value, true); err != nil {
+			return err
+		}
+		if value != "" {
+			secretValues = append(secretValues, value)
+		}
+		o.secretArgs = append(o.secretArgs, "--secret", "id="+id+",env="+name)
+	}
+	return nil
+}
+
+// argv returns the options as `docker build` arguments.
+func (o BuildOptions) argv() []string {
+	var args []string
+	for _, arg := range o.Args {
+		args = append(args, "--build-arg", arg)
+	}
+	if o.Target != "" {
+		args = append(args, "--target", o.Target)
+	}
+	if o.Platform != "" {
+		args = append(args, "--platform", o.Platform)
+	}
+	return append(args, o.secretArgs...)
+}
+
+// args renders argv as a shell-quoted argument string for the remote.
+func (o BuildOptions) args() string {
+	var args []string
+	for _, arg := range o.argv() {
+		args = append(args, shellQuote(arg))
+	}
+	return strings.Join(args, " ")
+}
+
+// hashInput is the part of the options that changes the built image.
+func (o BuildOptions) hashInput() string {
+	return fmt.Sprintf("args %q\ntarget %q\nplatform %q\n", o.Args, o.Target, o.Platform)
+}
+
+func buildCommand(cmd *cobra.Command, args []string) error {
+	opts, err := loadBuildOptions()
+	if err != nil {
+		return err
+	}
+	syncOpts, err := loadSyncOptions()
+	if err != nil {
+		return err
+	}
+	ref, err := contextImage(".", opts, syncOpts)
+	if err != nil {
+		return err
+	}
+
+	// Either build on each host, or build once locally and ship it
+	onHost := func(h Host, client *SSHClient, out io.Writer) (string, error) {
+		return client.buildOnHost(h, ref, opts, out)
+	}
+	if buildLocal {
+		archive := &imageArchive{ref: ref, opts: opts}
+		defer archive.cleanup()
+		onHost = func(h Host, client *SSHClient, out io.Writer) (string, error) {
+			return client.shipOnHost(h, archive, out)
+		}
+	}
+
+	if !buildAll {
+		h := currentHost()
+		client, err := connect(h)
+		if err != nil {
+			return err
+		}
+		defer client.Close()
+
+		id, err := onHost(h, client, os.Stdout)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("✓ %s: %s\n", ref, id)
+		return nil
+	}
+
+	fleet := fleetHosts()
+	fmt.Printf("Building %s on %d hosts...\n", ref, len(fleet))
+
+	var mu sync.Mutex
+	results := forEachHost(fleet, buildTimeout, func(h Host, client *SSHClient) (string, error) {
+		out := &prefixWriter{prefix: "[" + h.Name + "] ", mu: &mu, w: os.Stdout}
+		defer out.Flush()
+		return onHost(h, client, out)
+	})
+
+	failed := 0
+	fmt.Println()
+	for _, r := range results {
+		if r.Err != nil {
+			failed++
+			fmt.Printf("✗ %s: %v\n", r.Host.Name, r.Err)
+			continue
+		}
+		fmt.Printf("✓ %s: %s\n", r.Host.Name, r.Value)
+	}
+	if failed > 0 {
+		return fmt.Errorf("build failed on %d of %d hosts", failed, len(fleet))
+	}
+	return nil
+}
+
+// buildOnHost syncs the build context to h and builds ref there.
+func (s *SSHClient) buildOnHost(h Host, ref string, opts BuildOptions, out io.Writer) (string, error) {
+	fmt.Fprintln(out, "Syncing files...")
+	// Parallel hosts cannot share the terminal for a deletion prompt
+	if err := s.SyncFiles("./", h.RemotePath, out, !buildAll); err != nil {
+		return "", err
+	}
+	return s.BuildImage(h.RemotePath, ref, opts, rebuild, out)
+}
+
+// recordImage writes an image record under the remote state directory.
+func (s *SSHClient) recordImage(remotePath string, record ImageRecord) error {
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return err
+	}
+	dir := path.Join(remotePath, stateDir, "images")
+	file := path.Join(dir, strings.ReplaceAll(record.Ref, "/", "_")+".json")
+	writeCmd := fmt.Sprintf("mkdir -p %s && cat > %s", shellQuote(dir), shellQuote(file))
+	if _, err := s.RunCommandWithInput(writeCmd, bytes.NewReader(data)); err != nil {
+		return fmt.Errorf("failed to record image: %w", err)
+	}
+	return nil
+}
+
+// prefixWriter prefixes every line written through it, so output from
+// parallel hosts stays readable.
+type prefixWriter struct {
+	prefix string
+	mu     *sync.Mutex
+	w      io.Writer
+	buf    []byte
+}
+
+func (p *prefixWriter) Write(b []byte) (int, error) {
+	// Stdout and stderr of a session are copied concurrently
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.buf = append(p.buf, b...)
+	for {
+		i := bytes.IndexByte(p.buf, '\n')
+		if i < 0 {
+			break
+		}
+		fmt.Fprintf(p.w, "%s%s\n", p.prefix, p.buf[:i])
+		p.buf = p.buf[i+1:]
+	}
+	return len(b), nil
+}
+
+// Flush writes out a last line that did not end in a newline, such as the
+// final error of a failed build.
+func (p *prefixWriter) Flush() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.buf) > 0 {
+		fmt.Fprintf(p.w, "%s%s\n", p.prefix, p.buf)
+		p.buf = nil
+	}
+}
@@ -0,0 +1,317 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// projectConfigName is the repo-local config file discovered from the cwd.
+const projectConfigName = ".osiris.yaml"
+
+var (
+	homeConfigFile    string
+	projectConfigFile string
+	showOrigin        bool
+
+	// envBindings maps config keys to their dedicated environment variables.
+	envBindings = map[string]string{
+		"remote":      "OSIRIS_REMOTE",
+		"password":    "OSIRIS_REMOTE_PASSWORD",
+		"remote-path": "OSIRIS_REMOTE_PATH",
+		"image":       "OSIRIS_IMAGE",
+		"container":   "OSIRIS_CONTAINER",
+		"host":        "OSIRIS_HOST",
+		"profile":     "OSIRIS_PROFILE",
+	}
+
+	// configKeys are the scalar settings shown by `config show`, in display order.
+	configKeys = []string{"remote", "remote-path", "results-path", "remote-results-path", "dockerfile", "image", "container", "password", "capacity", "host", "profile"}
+
+	// noAutoEnvKeys ignore the generic variable AutomaticEnv would consult;
+	// shells commonly set $HOST to the machine name.
+	noAutoEnvKeys = map[string]bool{"host": true, "profile": true}
+
+	// secretKeys are masked whenever config values are printed.
+	secretKeys = map[string]bool{"password": true}
+)
+
+func newConfigCommand() *cobra.Command {
+	configCmd := &cobra.Command{
+		Use:   "config",
+		Short: "Create, validate and inspect the configuration",
+	}
+
+	initCmd := &cobra.Command{
+		Use:   "init",
+		Short: "Interactively write a starter config from your SSH host aliases",
+		Args:  cobra.NoArgs,
+		RunE:  configInitCommand,
+	}
+	initCmd.Flags().StringVarP(&initOutput, "output", "o", "", "File to write (default: ~/.osiris.yaml, or ./.osiris.yaml with --project)")
+	initCmd.Flags().BoolVar(&initForce, "force", false, "Overwrite an existing file")
+	initCmd.Flags().BoolVar(&initProject, "project", false, "Write a project config with only non-secret settings")
+
+	showCmd := &cobra.Command{
+		Use:   "show",
+		Short: "Print the merged configuration with secrets masked",
+		Args:  cobra.NoArgs,
+		RunE:  configShowCommand,
+	}
+	showCmd.Flags().BoolVar(&showOrigin, "origin", false, "Show which layer each value comes from (flag, env, profile, host, project, home, default)")
+
+	configCmd.AddCommand(
+		initCmd,
+		&cobra.Command{
+			Use:   "validate",
+			Short: "Check config files for unknown keys, bad values and missing settings",
+			Args:  cobra.NoArgs,
+			// Load errors are reported as findings instead of aborting
+			PersistentPreRunE: func(cmd *cobra.Command, args []string) error { return nil },
+			RunE:              configValidateCommand,
+		},
+		showCmd,
+	)
+	return configCmd
+}
+
+func configShowCommand(cmd *cobra.Command, args []string) error {
+	values := configValues()
+
+	if !showOrigin {
+		for _, key := range configKeys {
+			fmt.Printf("%s: %s\n", key, values[key])
+		}
+		printHosts()
+		printEnv()
+		return nil
+	}
+
+	for _, layer := range []struct{ name, file string }{{"home", homeConfigFile}, {"project", projectConfigFile}} {
+		if layer.file != "" {
+			fmt.Printf("%s config: %s\n", layer.name, layer.file)
+		}
+	}
+	fmt.Println()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tVALUE\tORIGIN")
+	for _, key := range configKeys {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", key, values[key], configOrigin(key))
+	}
+	return w.Flush()
+}
+
+// configValues returns the effective value of every key in configKeys, after
+// profile and host selection, with secrets masked.
+func configValues() map[string]string {
+	values := map[string]string{
+		"remote":              remote,
+		"remote-path":         remotePath,
+		"results-path":        resultsPath,
+		"remote-results-path": remoteResults,
+		"dockerfile":          dockerfilePath,
+		"image":               image,
+		"container":           container,
+		"password":            password,
+		"capacity":            strconv.Itoa(capacity),
+		"host":                hostName,
+		"profile":             profileName,
+	}
+	for key, value := range values {
+		if secretKeys[key] {
+			values[key] = maskSecret(value)
+		}
+	}
+	return values
+}
+
+func maskSecret(value string) string {
+	if value == "" {
+		return ""
+	}
+	return "********"
+}
+
+// printEnv prints the config env map, masking values of secret-looking names.
+func printEnv() {
+	values := viper.GetStringMapString("env")
+	if len(values) == 0 {
+		return
+	}
+	fmt.Println("env:")
+	for _, key := range sortedKeys(viper.GetStringMap("env")) {
+		value := values[key]
+		if secretEnvPattern.MatchString(key) {
+			value = maskSecret(value)
+		}
+		fmt.Printf("  %s: %s\n", strings.ToUpper(key), value)
+	}
+}
+
+// printHosts prints the fleet as resolved, after inheritance from top-level values.
+func printHosts() {
+	if len(hosts) == 0 {
+		return
+	}
+	fmt.Println("hosts:")
+	for _, h := range fleetHosts() {
+		fmt.Printf("  %s:\n", h.Name)
+		fmt.Printf("    remote: %s\n", h.Remote)
+		fmt.Printf("    remote-path: %s\n", h.RemotePath)
+		if len(h.Tags) > 0 {
+			fmt.Printf("    tags: [%s]\n", strings.Join(h.Tags, ", "))
+		}
+		fmt.Printf("    capacity: %d\n", h.Capacity)
+		if h.Password != "" {
+			fmt.Printf("    password: %s\n", maskSecret(h.Password))
+		}
+	}
+}
+
+// configOrigin names the highest-precedence layer that sets key.
+func configOrigin(key string) string {
+	_, origin := configLayer(key)
+	if origin == "flag" || strings.HasPrefix(origin, "env") {
+		return origin
+	}
+
+	// Host selection overrides the file values of the connection settings
+	// it covers
+	if hostName != "" && !isHostSelector(hostName) {
+		switch key {
+		case "remote", "remote-path", "password", "capacity":
+			return "host " + hostName
+		}
+	}
+	return origin
+}
+
+// configLayer finds the highest-precedence layer that sets key, leaving out
+// host selection, and returns the value it sets there with the layer's name.
+// Layers are read from their own maps, in the order viper applies them.
+func configLayer(key string) (string, string) {
+	if f := rootCmd.PersistentFlags().Lookup(key); f != nil && f.Changed {
+		return f.Value.String(), "flag"
+	}
+	if name := viperEnvName(key); !noAutoEnvKeys[key] && os.Getenv(name) != "" {
+		return os.Getenv(name), "env (" + name + ")"
+	}
+	if name, ok := envBindings[key]; ok && os.Getenv(name) != "" {
+		return os.Getenv(name), "env (" + name + ")"
+	}
+
+	profiles := viper.GetStringMap("profiles")
+	for _, p := range []string{profileName, defaultProfile} {
+		if block, ok := profiles[p].(map[string]interface{}); ok {
+			if value, set := block[key]; set {
+				return fmt.Sprint(value), "profile " + p
+			}
+		}
+	}
+
+	if value, ok := fileValue(projectConfigFile, key); ok {
+		return value, "project"
+	}
+	if value, ok := fileValue(homeConfigFile, key); ok {
+		return value, "home"
+	}
+	if f := rootCmd.PersistentFlags().Lookup(key); f != nil {
+		return f.DefValue, "default"
+	}
+	return "", "default"
+}
+
+// setExplicitly reports whether key comes from a flag or the environment,
+// which win over the host entry.
+func setExplicitly(key string) bool {
+	_, origin := configLayer(key)
+	return origin == "flag" || strings.HasPrefix(origin, "env")
+}
+
+// viperEnvName is the variable viper.AutomaticEnv consults for key.
+func viperEnvName(key string) string {
+	return strings.ToUpper(key)
+}
+
+// getWithoutAutoEnv reads key like viper.GetString but skips the generic
+// AutomaticEnv variable; the dedicated OSIRIS_ variable still applies. Keys
+// using it must be in noAutoEnvKeys.
+func getWithoutAutoEnv(key string) string {
+	value, _ := configLayer(key)
+	return value
+}
+
+// fileValue returns the value the config file at path sets for key itself.
+func fileValue(path, key string) (string, bool) {
+	if path == "" {
+		return "", false
+	}
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil || !v.InConfig(key) {
+		return "", false
+	}
+	return v.GetString(key), true
+}
+
+// fileSets reports whether the config file at path sets key itself.
+func fileSets(path, key string) bool {
+	if path == "" {
+		return false
+	}
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		return false
+	}
+	return v.InConfig(key)
+}
+
+// discoverProjectConfig walks up from the cwd looking for .osiris.yaml,
+// stopping at the git root. Outside a git repo only the cwd is checked.
+func discoverProjectConfig() string {
+	dir, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+
+	inRepo := false
+	for d := dir; ; d = filepath.Dir(d) {
+		if _, err := os.Stat(filepath.Join(d, ".git")); err == nil {
+			inRepo = true
+			break
+		}
+		if filepath.Dir(d) == d {
+			break
+		}
+	}
+
+	for d := dir; ; d = filepath.Dir(d) {
+		candidate := filepath.Join(d, projectConfigName)
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate
+		}
+		if !inRepo {
+			return ""
+		}
+		if _, err := os.Stat(filepath.Join(d, ".git")); err == nil || filepath.Dir(d) == d {
+			return ""
+		}
+	}
+}
+
+func sameFile(a, b string) bool {
+	if a == "" || b == "" {
+		return false
+	}
+	ia, errA := os.Stat(a)
+	ib, errB := os.Stat(b)
+	return errA == nil && errB == nil && os.SameFile(ia, ib)
+}
@@ -0,0 +1,166 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/kevinburke/ssh_config"
+	"github.com/spf13/cobra"
+)
+
+var (
+	initOutput  string
+	initForce   bool
+	initProject bool
+)
+
+func configInitCommand(cmd *cobra.Command, args []string) error {
+	output := initOutput
+	if output == "" {
+		if initProject {
+			output = projectConfigName
+		} else {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return err
+			}
+			output = filepath.Join(home, ".osiris.yaml")
+		}
+	}
+	if _, err := os.Stat(output); err == nil && !initForce {
+		return fmt.Errorf("%s already exists (use --force to overwrite)", output)
+	}
+
+	in := bufio.NewReader(os.Stdin)
+	var b strings.Builder
+
+	if initProject {
+		// Project files carry only non-secret, repo-specific settings
+		fmt.Println("Creating a project config (commit it with the repository)")
+		fmt.Fprintf(&b, "dockerfile: %q\n", prompt(in, "Dockerfile (relative to the repo root)", dockerfilePath))
+		fmt.Fprintf(&b, "results-path: %q\n", prompt(in, "Local results path", defaultString(resultsPath, "./corpus")))
+		fmt.Fprintf(&b, "image: %q\n", prompt(in, "Docker image name", image))
+		return writeStarterConfig(output, b.String())
+	}
+
+	aliases, err := sshAliases()
+	if err != nil {
+		return err
+	}
+	if len(aliases) == 0 {
+		return fmt.Errorf("no host aliases found in ~/.ssh/config; add one first")
+	}
+
+	fmt.Println("Host aliases in ~/.ssh/config:")
+	for i, alias := range aliases {
+		fmt.Printf("  %d) %s (%s)\n", i+1, alias, ssh_config.Get(alias, "HostName"))
+	}
+	choice := prompt(in, "Remote (number or alias)", aliases[0])
+	alias := choice
+	if n, err := strconv.Atoi(choice); err == nil && n >= 1 && n <= len(aliases) {
+		alias = aliases[n-1]
+	}
+
+	// Probe the remote for the tools osiris-lite relies on
+	defaultPath := path.Join("~", filepath.Base(mustGetwd()))
+	fmt.Printf("Probing %s...\n", alias)
+	client, err := connect(Host{Name: alias, Remote: alias, Password: password})
+	if err != nil {
+		fmt.Printf("⚠ %v\n", err)
+	} else {
+		defer client.Close()
+		if home, err := client.RunCommand("echo $HOME"); err == nil {
+			defaultPath = path.Join(strings.TrimSpace(home), filepath.Base(mustGetwd()))
+		}
+		for _, tool := range []struct{ name, check string }{
+			{"docker", "docker version --format '{{.Server.Version}}'"},
+			{"rsync", "rsync --version | head -1"},
+			{"cores", "nproc"},
+		} {
+			if out, err := client.RunCommand(tool.check); err == nil {
+				fmt.Printf("  ✓ %s: %s\n", tool.name, strings.TrimSpace(out))
+			} else {
+				fmt.Printf("  ✗ %s: not available\n", tool.name)
+			}
+		}
+	}
+
+	fmt.Fprintf(&b, "remote: %q\n", alias)
+	fmt.Fprintf(&b, "remote-path: %q\n", prompt(in, "Remote working directory", defaultPath))
+	fmt.Fprintf(&b, "results-path: %q\n", prompt(in, "Local results path", defaultString(resultsPath, "./corpus")))
+	fmt.Fprintf(&b, "dockerfile: %q\n", prompt(in, "Dockerfile (relative to remote-path)", dockerfilePath))
+	fmt.Fprintf(&b, "image: %q\n", prompt(in, "Docker image name", image))
+	fmt.Fprintf(&b, "container: %q\n", prompt(in, "Container name prefix", container))
+	b.WriteString("# password: \"\" # Prefer SSH keys or OSIRIS_REMOTE_PASSWORD\n")
+
+	return writeStarterConfig(output, b.String())
+}
+
+func writeStarterConfig(output, content string) error {
+	if err := os.WriteFile(output, []byte("# Generated by osiris-lite config init\n"+content), 0600); err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	fmt.Printf("Wrote %s; check it with: osiris-lite config validate\n", output)
+	return nil
+}
+
+// sshAliases lists concrete Host aliases from ~/.ssh/config, skipping wildcards.
+func sshAliases() ([]string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(filepath.Join(home, ".ssh", "config"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read SSH config: %w", err)
+	}
+	defer f.Close()
+
+	cfg, err := ssh_config.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse SSH config: %w", err)
+	}
+
+	var aliases []string
+	for _, h := range cfg.Hosts {
+		for _, p := range h.Patterns {
+			if s := p.String(); !strings.ContainsAny(s, "*?!") {
+				aliases = append(aliases, s)
+			}
+		}
+	}
+	return aliases, nil
+}
+
+func prompt(in *bufio.Reader, question, fallback string) string {
+	if fallback != "" {
+		fmt.Printf("%s [%s]: ", question, fallback)
+	} else {
+		fmt.Printf("%s: ", question)
+	}
+	line, _ := in.ReadString('\n')
+	if line = strings.TrimSpace(line); line != "" {
+		return line
+	}
+	return fallback
+}
+
+func defaultString(value, fallback string) string {
+	if value != "" {
+		return value
+	}
+	return fallback
+}
+
+func mustGetwd() string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return "project"
+	}
+	return wd
+}
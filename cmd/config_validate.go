@@ -0,0 +1,336 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/kevinburke/ssh_config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// valueKind is the expected shape of a config value.
+type valueKind int
+
+const (
+	kindString valueKind = iota
+	kindInt
+	kindList
+	kindMap
+)
+
+func (k valueKind) String() string {
+	return [...]string{"a string", "an integer", "a list", "a map"}[k]
+}
+
+var (
+	// configSchema lists every key accepted at the top level of a config file
+	// and inside a profile.
+	configSchema = map[string]valueKind{
+		"remote":              kindString,
+		"remote-path":         kindString,
+		"results-path":        kindString,
+		"remote-results-path": kindString,
+		"dockerfile":          kindString,
+		"image":               kindString,
+		"container":           kindString,
+		"password":            kindString,
+		"capacity":            kindInt,
+		"host":                kindString,
+		"profile":             kindString,
+		"hosts":               kindMap,
+		"profiles":            kindMap,
+		"env":                 kindMap,
+		"docker":              kindMap,
+		"build":               kindMap,
+		"workspace":           kindMap,
+		"sync":                kindMap,
+		"fuzzers":             kindMap,
+	}
+
+	// hostSchema lists the keys accepted inside a hosts entry.
+	hostSchema = map[string]valueKind{
+		"remote":      kindString,
+		"remote-path": kindString,
+		"tags":        kindList,
+		"capacity":    kindInt,
+		"password":    kindString,
+	}
+
+	// commandRequirements are the settings each command cannot work without.
+	commandRequirements = []struct {
+		command string
+		keys    []string
+	}{
+		{"run", []string{"remote", "remote-path"}},
+		{"schedule", []string{"remote", "remote-path"}},
+		{"build", []string{"remote", "remote-path"}},
+		{"sync", []string{"remote", "remote-path"}},
+		{"jobs", []string{"remote", "remote-path"}},
+		{"status", []string{"remote"}},
+		{"kill", []string{"remote"}},
+		{"logs", []string{"remote"}},
+		{"pull", []string{"remote", "remote-path", "results-path"}},
+		{"results", []string{"remote", "remote-path"}},
+		{"push-corpus", []string{"remote", "remote-path"}},
+		{"corpus merge", []string{"remote", "remote-path", "results-path"}},
+	}
+)
+
+// configIssue is one finding of config validate.
+type configIssue struct {
+	fatal bool
+	where string
+	msg   string
+}
+
+func configValidateCommand(cmd *cobra.Command, args []string) error {
+	var issues []configIssue
+	if configErr != nil {
+		issues = append(issues, configIssue{true, "config", configErr.Error()})
+	}
+
+	// Check each file on its own so findings point at the right file
+	layers := []struct{ name, file string }{{"home", homeConfigFile}, {"project", projectConfigFile}}
+	checked := 0
+	for _, layer := range layers {
+		if layer.file == "" {
+			continue
+		}
+		checked++
+		fmt.Printf("Checking %s config: %s\n", layer.name, layer.file)
+		v := viper.New()
+		v.SetConfigFile(layer.file)
+		if err := v.ReadInConfig(); err != nil {
+			issues = append(issues, configIssue{true, layer.name, err.Error()})
+			continue
+		}
+		issues = append(issues, validateSettings(layer.name, v.AllSettings(), true)...)
+	}
+	if checked == 0 && configErr == nil {
+		fmt.Println("No config file found; checking flags and environment only")
+	}
+
+	issues = append(issues, validateEffective()...)
+
+	errors := 0
+	for _, issue := range issues {
+		mark := "⚠"
+		if issue.fatal {
+			mark = "✗"
+			errors++
+		}
+		fmt.Printf("%s %s: %s\n", mark, issue.where, issue.msg)
+	}
+
+	if errors > 0 {
+		return fmt.Errorf("config has %d error(s)", errors)
+	}
+	if len(issues) == 0 {
+		fmt.Println("✓ Configuration is valid")
+	} else {
+		fmt.Println("✓ No errors, see warnings above")
+	}
+	return nil
+}
+
+// validateSettings checks keys and value types of one settings map. Profiles
+// are validated recursively but may not nest further profiles.
+func validateSettings(where string, settings map[string]interface{}, topLevel bool) []configIssue {
+	var issues []configIssue
+	for _, key := range sortedKeys(settings) {
+		value := settings[key]
+		kind, known := configSchema[key]
+		if !known || (!topLevel && key == "profiles") {
+			issues = append(issues, unknownKey(where, key, configSchema))
+			continue
+		}
+		if err := checkKind(value, kind); err != nil {
+			issues = append(issues, configIssue{true, where, fmt.Sprintf("%s %v", key, err)})
+			continue
+		}
+
+		switch key {
+		case "capacity":
+			if n, _ := strconv.Atoi(fmt.Sprint(value)); n < 0 {
+				issues = append(issues, configIssue{true, where, "capacity must not be negative"})
+			}
+		case "password":
+			if where == "project" {
+				issues = append(issues, configIssue{false, where, "password should not live in a project config that may be committed"})
+			}
+		case "hosts":
+			for _, name := range sortedKeys(value.(map[string]interface{})) {
+				issues = append(issues, validateHost(where+" hosts."+name, value.(map[string]interface{})[name])...)
+			}
+		case "profiles":
+			for _, name := range sortedKeys(value.(map[string]interface{})) {
+				block, ok := value.(map[string]interface{})[name].(map[string]interface{})
+				if !ok {
+					issues = append(issues, configIssue{true, where + " profiles." + name, "profile must be a map of settings"})
+					continue
+				}
+				issues = append(issues, validateSettings(where+" profiles."+name, block, false)...)
+			}
+		}
+	}
+	return issues
+}
+
+func validateHost(where string, raw interface{}) []configIssue {
+	entry, ok := raw.(map[string]interface{})
+	if !ok {
+		if raw == nil {
+			return nil
+		}
+		return []configIssue{{true, where, "host entry must be a map"}}
+	}
+
+	var issues []configIssue
+	for _, key := range sortedKeys(entry) {
+		kind, known := hostSchema[key]
+		if !known {
+			issues = append(issues, unknownKey(where, key, hostSchema))
+			continue
+		}
+		if err := checkKind(entry[key], kind); err != nil {
+			issues = append(issues, configIssue{true, where, fmt.Sprintf("%s %v", key, err)})
+		}
+	}
+	return issues
+}
+
+// validateEffective checks the merged values: SSH aliases resolve and every
+// command has the settings it needs.
+func validateEffective() []configIssue {
+	var issues []configIssue
+
+	for _, h := range fleetHosts() {
+		if h.Remote == "" {
+			continue
+		}
+		if ssh_config.Get(h.Remote, "HostName") == "" {
+			issues = append(issues, configIssue{true, "host " + h.Name, fmt.Sprintf("SSH alias %q has no HostName in ~/.ssh/config", h.Remote)})
+		}
+	}
+
+	if _, err := os.Stat(dockerfilePath); err != nil {
+		issues = append(issues, configIssue{false, "dockerfile", fmt.Sprintf("%s not found relative to the current directory (it is synced from here)", dockerfilePath)})
+	}
+
+	if _, err := loadDockerOptions(); err != nil {
+		issues = append(issues, configIssue{true, "docker", err.Error()})
+	}
+
+	if _, err := loadBuildOptions(); err != nil {
+		issues = append(issues, configIssue{true, "build", err.Error()})
+	}
+
+	if opts, err := loadSyncOptions(); err != nil {
+		issues = append(issues, configIssue{true, "sync", err.Error()})
+	} else if _, err := syncFilters(".", opts); err != nil {
+		issues = append(issues, configIssue{true, "sync", err.Error()})
+	}
+
+	if remoteResults != "" || resultsPath != "" {
+		if _, err := remoteResultsDir(); err != nil {
+			issues = append(issues, configIssue{remoteResults != "", "remote-results-path", err.Error()})
+		}
+	}
+
+	if _, err := loadWorkspaceOptions(); err != nil {
+		issues = append(issues, configIssue{true, "workspace", err.Error()})
+	}
+
+	if _, err := loadAdapters(); err != nil {
+		issues = append(issues, configIssue{true, "fuzzers", err.Error()})
+	}
+
+	values := configValues()
+	for _, req := range commandRequirements {
+		var missing []string
+		for _, key := range req.keys {
+			if values[key] == "" {
+				missing = append(missing, key)
+			}
+		}
+		if len(missing) > 0 {
+			issues = append(issues, configIssue{false, req.command, "missing " + strings.Join(missing, ", ")})
+		}
+	}
+	return issues
+}
+
+func checkKind(value interface{}, kind valueKind) error {
+	ok := false
+	switch kind {
+	case kindString:
+		switch value.(type) {
+		case string, int, int64, float64, bool:
+			ok = true
+		}
+	case kindInt:
+		switch v := value.(type) {
+		case int, int64:
+			ok = true
+		case string:
+			_, err := strconv.Atoi(v)
+			ok = err == nil
+		}
+	case kindList:
+		_, ok = value.([]interface{})
+	case kindMap:
+		_, ok = value.(map[string]interface{})
+	}
+	if !ok {
+		return fmt.Errorf("must be %s, got %v", kind, value)
+	}
+	return nil
+}
+
+func unknownKey(where, key string, schema map[string]valueKind) configIssue {
+	msg := fmt.Sprintf("unknown key %q", key)
+	best, bestDistance := "", 3
+	for known := range schema {
+		if d := editDistance(key, known); d < bestDistance {
+			best, bestDistance = known, d
+		}
+	}
+	if best != "" {
+		msg += fmt.Sprintf(" (did you mean %q?)", best)
+	}
+	return configIssue{true, where, msg}
+}
+
+// editDistance is the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,322 @@
+package cmd
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	seedCorpus string
+	corpusDest string
+
+	// seedRecord is the seed corpus uploaded by this run, recorded with its jobs
+	seedRecord *SeedCorpus
+)
+
+// SeedCorpus records a local corpus uploaded to the remote before a run.
+type SeedCorpus struct {
+	Source string    `json:"source"`
+	Dest   string    `json:"dest"`
+	Digest string    `json:"digest"`
+	Files  int       `json:"files"`
+	Added  int       `json:"added"`
+	Pushed time.Time `json:"pushed"`
+}
+
+func newPushCorpusCommand() *cobra.Command {
+	pushCmd := &cobra.Command{
+		Use:   "push-corpus <dir>",
+		Short: "Upload a local corpus to the remote corpus directory",
+		Long: `Upload a local corpus to the remote corpus directory.
+
+Files whose content is already on the remote are skipped. The corpus lands in
+the corpus directory set by the project's fuzzer config (corpusDir in
+echidna.yaml, corpusDirectory in medusa.json), else in remote-results-path
+(or results-path). A directory shared between jobs is written in its shared
+location.`,
+		Args: cobra.ExactArgs(1),
+		RunE: pushCorpusCommand,
+	}
+	pushCmd.Flags().StringVar(&corpusDest, "dest", "", "Corpus directory relative to the remote project (default: the fuzzer config's corpus directory, else the results directory)")
+	pushCmd.Flags().StringVar(&fuzzerName, "fuzzer", "", "Fuzzer adapter whose config sets the corpus directory, when the project has several")
+	return pushCmd
+}
+
+func pushCorpusCommand(cmd *cobra.Command, args []string) error {
+	wsOpts, err := loadWorkspaceOptions()
+	if err != nil {
+		return err
+	}
+	dest, err := seedDest(".", nil)
+	if err != nil {
+		return err
+	}
+
+	client, err := connect(currentHost())
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	_, err = client.PushCorpus(remotePath, args[0], dest, wsOpts)
+	return err
+}
+
+// seedDest returns the project-relative corpus directory seeds go to: --dest
+// or --seed-dest, else the corpus directory of the fuzzer's config, else the
+// results directory. Without settings from a run, the fuzzer is found from
+// the config files of the project at root.
+func seedDest(root string, settings *FuzzerSettings) (string, error) {
+	dir := corpusDest
+	if dir == "" && settings == nil {
+		var err error
+		if settings, err = configuredFuzzer(root); err != nil {
+			return "", err
+		}
+	}
+	if dir == "" && settings != nil {
+		dir = settings.Corpus
+	}
+	if dir == "" {
+		return remoteResultsDir()
+	}
+	rel, ok := projectRelative(dir)
+	if !ok {
+		return "", fmt.Errorf("invalid corpus directory %q: expected a directory inside the project", dir)
+	}
+	return rel, nil
+}
+
+// configuredFuzzer returns the settings of the fuzzer whose config file the
+// project at root has and that sets a corpus directory, or of --fuzzer. It
+// returns nil when there is none.
+func configuredFuzzer(root string) (*FuzzerSettings, error) {
+	registry, err := loadAdapters()
+	if err != nil {
+		return nil, err
+	}
+	if fuzzerName != "" {
+		a, err := lookupAdapter(registry, fuzzerName)
+		if err != nil {
+			return nil, err
+		}
+		registry = []FuzzerAdapter{a}
+	}
+
+	var found []*FuzzerSettings
+	for _, a := range registry {
+		spec := a.Spec()
+		if spec.Config == "" {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(root, filepath.FromSlash(spec.Config))); err != nil {
+			continue
+		}
+		command, err := fuzzerCommand(a, nil)
+		if err != nil {
+			continue
+		}
+		settings, _, err := a.Prepare(root, command, jobEnv{values: make(map[string]string), secret: make(map[string]bool)})
+		if err != nil {
+			return nil, err
+		}
+		if settings.Corpus != "" {
+			found = append(found, settings)
+		}
+	}
+	if len(found) > 1 {
+		var names []string
+		for _, f := range found {
+			names = append(names, fmt.Sprintf("%s: %s", f.Name, f.Corpus))
+		}
+		return nil, fmt.Errorf("several fuzzer configs set a corpus directory (%s); pass --dest or --fuzzer", strings.Join(names, ", "))
+	}
+	if len(found) == 0 {
+		return nil, nil
+	}
+	return found[0], nil
+}
+
+// corpusTarget returns where a corpus directory lives on the remote: the
+// shared location for shared corpora, else the synced tree that jobs snapshot.
+func corpusTarget(remotePath, dir string, wsOpts WorkspaceOptions) string {
+	for _, shared := range wsOpts.SharedCorpus {
+		if shared == dir {
+			return sharedCorpusDir(remotePath, dir)
+		}
+	}
+	return path.Join(remotePath, dir)
+}
+
+// PushCorpus uploads the files of localDir that the remote corpus directory
+// does not already have, compared by SHA-256. A file whose name is taken by
+// different content gets the start of its hash added to the name. The upload
+// is recorded under .osiris/seeds.
+func (s *SSHClient) PushCorpus(remotePath, localDir, dest string, wsOpts WorkspaceOptions) (*SeedCorpus, error) {
+	local, err := listFiles(localDir, localDir)
+	if err != nil {
+		return nil, err
+	}
+	if len(local) == 0 {
+		return nil, fmt.Errorf("no files in %s", localDir)
+	}
+
+	target := corpusTarget(remotePath, dest, wsOpts)
+	remote, err := s.remoteHashes(target)
+	if err != nil {
+		return nil, err
+	}
+	have := make(map[string]bool)
+	for _, hash := range remote {
+		have[hash] = true
+	}
+
+	var upload []ManifestFile
+	for _, f := range local {
+		if have[f.SHA256] {
+			continue
+		}
+		have[f.SHA256] = true
+		if hash, taken := remote[f.Path]; taken && hash != f.SHA256 {
+			ext := path.Ext(f.Path)
+			f.Path = strings.TrimSuffix(f.Path, ext) + "-" + f.SHA256[:8] + ext
+		}
+		upload = append(upload, f)
+	}
+
+	// The digest identifies the corpus by content, whatever it was named
+	unique := make(map[string]bool)
+	var hashes []string
+	for _, f := range local {
+		if !unique[f.SHA256] {
+			unique[f.SHA256] = true
+			hashes = append(hashes, f.SHA256)
+		}
+	}
+	sort.Strings(hashes)
+	digest := sha256.Sum256([]byte(strings.Join(hashes, "\n")))
+
+	fmt.Printf("Seed corpus: %d file(s), %d new, %d already on the remote\n", len(local), len(upload), len(local)-len(upload))
+	if len(upload) > 0 {
+		if err := s.uploadFiles(localDir, target, local, upload); err != nil {
+			return nil, err
+		}
+	}
+	fmt.Printf("✓ Seeded %s\n", target)
+
+	if !isProtected(dest) && target == path.Join(remotePath, dest) {
+		fmt.Printf("⚠ %s is not protected from sync --delete; add it to sync.protect or the next sync removes it\n", dest)
+	}
+
+	record := &SeedCorpus{
+		Source: localDir,
+		Dest:   dest,
+		Digest: hex.EncodeToString(digest[:]),
+		Files:  len(local),
+		Added:  len(upload),
+		Pushed: time.Now().UTC(),
+	}
+	if abs, err := filepath.Abs(localDir); err == nil {
+		record.Source = abs
+	}
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	dir := path.Join(remotePath, stateDir, "seeds")
+	file := path.Join(dir, record.Digest[:12]+".json")
+	if _, err := s.RunCommandWithInput(fmt.Sprintf("mkdir -p %s && cat > %s", shellQuote(dir), shellQuote(file)), bytes.NewReader(data)); err != nil {
+		return nil, fmt.Errorf("failed to record seed corpus: %w", err)
+	}
+	return record, nil
+}
+
+// remoteHashes returns the SHA-256 of every file below dir, by relative path.
+// A missing directory has no files.
+func (s *SSHClient) remoteHashes(dir string) (map[string]string, error) {
+	output, err := s.RunCommand(fmt.Sprintf("d=%s; [ ! -d \"$d\" ] || { cd \"$d\" && find . -type f -exec sha256sum {} +; }", shellQuote(dir)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash remote corpus: %w", err)
+	}
+	hashes := make(map[string]string)
+	for _, line := range strings.Split(output, "\n") {
+		hash, name, ok := strings.Cut(line, "  ")
+		if !ok {
+			continue
+		}
+		hashes[strings.TrimPrefix(name, "./")] = hash
+	}
+	return hashes, nil
+}
+
+// uploadFiles streams the selected files as a gzipped tar into dir on the
+// remote, under their upload names.
+func (s *SSHClient) uploadFiles(localDir, dir string, local, upload []ManifestFile) error {
+	// Renamed uploads still read from their original file
+	source := make(map[string]string)
+	for _, f := range local {
+		source[f.SHA256] = f.Path
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		gz := gzip.NewWriter(pw)
+		tw := tar.NewWriter(gz)
+		err := func() error {
+			for _, f := range upload {
+				data, err := os.ReadFile(filepath.Join(localDir, filepath.FromSlash(source[f.SHA256])))
+				if err != nil {
+					return err
+				}
+				if err := tw.WriteHeader(&tar.Header{Name: f.Path, Mode: 0644, Size: int64(len(data)), ModTime: time.Now()}); err != nil {
+					return err
+				}
+				if _, err := tw.Write(data); err != nil {
+					return err
+				}
+			}
+			if err := tw.Close(); err != nil {
+				return err
+			}
+			return gz.Close()
+		}()
+		pw.CloseWithError(err)
+	}()
+
+	if _, err := s.RunCommandWithInput(fmt.Sprintf("mkdir -p %s && tar -xzf - -C %s", shellQuote(dir), shellQuote(dir)), pr); err != nil {
+		pr.CloseWithError(err)
+		return fmt.Errorf("failed to upload corpus: %w", err)
+	}
+	return nil
+}
+
+// isProtected reports whether sync --delete leaves the project directory dir alone.
+func isProtected(dir string) bool {
+	if rel, err := remoteResultsDir(); err == nil && rel == dir {
+		return true
+	}
+	opts, err := loadSyncOptions()
+	if err != nil {
+		return false
+	}
+	for _, p := range opts.Protect {
+		if strings.Trim(p, "/") == dir {
+			return true
+		}
+	}
+	return false
+}
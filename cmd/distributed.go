@@ -0,0 +1,363 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// campaignPrefix starts the job set ID of a distributed campaign.
+const campaignPrefix = "dist-"
+
+var (
+	distributed      int
+	exchangeInterval time.Duration
+)
+
+// ExchangeStats is written to the campaign's set directory after every
+// exchange round so status can report on the campaign.
+type ExchangeStats struct {
+	Updated   time.Time `json:"updated"`
+	Unique    int       `json:"unique"`
+	Exchanged int       `json:"exchanged"`
+}
+
+// corpusExchange tracks which corpus entries, by content hash, each instance
+// of a campaign has, and where each entry was first seen.
+type corpusExchange struct {
+	setID, rel string
+	origin     map[string]string
+	paths      map[string]string
+	has        map[string]map[string]bool
+	exchanged  int
+	scanned    bool
+}
+
+func isCampaign(set string) bool {
+	return strings.HasPrefix(set, campaignPrefix)
+}
+
+// runDistributed starts n instances of the command as one campaign and runs
+// the corpus exchange until they finish. Instances get an {{instance}}
+// parameter, 1 to n, for use in the command.
+func runDistributed(client *SSHClient, n int, ref, command string, env jobEnv, dockerOpts DockerOptions, buildOpts BuildOptions, gitInfo *GitInfo, wsOpts WorkspaceOptions) error {
+	rel, err := remoteResultsDir()
+	if err != nil {
+		return err
+	}
+	for _, dir := range wsOpts.SharedCorpus {
+		if dir == rel {
+			return fmt.Errorf("--distributed exchanges per-instance corpora, but %s is a shared corpus", rel)
+		}
+	}
+
+	id, err := client.BuildImage(remotePath, ref, buildOpts, rebuild, os.Stdout)
+	if err != nil {
+		return err
+	}
+
+	setID := campaignPrefix + randomSuffix()
+	var jobs []Job
+	for i := 1; i <= n; i++ {
+		params := map[string]string{"instance": strconv.Itoa(i)}
+		job := newJob(container, ref, renderTemplate(command, params))
+		job.Set = setID
+		job.Params = params
+		job.Env = env.masked()
+		job.ImageID = id
+		job.Docker = dockerOpts
+		job.Build = buildOpts
+		job.Git = gitInfo
+		job.SharedCorpus = wsOpts.SharedCorpus
+		if err := client.CreateJob(remotePath, job); err != nil {
+			return err
+		}
+		jobs = append(jobs, job)
+	}
+
+	// Every instance runs at once
+	if err := client.StartJobSet(remotePath, setID, jobs, n, env); err != nil {
+		return err
+	}
+	fmt.Printf("Started campaign %s: %d instances\n", setID, n)
+	return client.exchangeLoop(remotePath, setID, rel, exchangeInterval)
+}
+
+func newCorpusExchangeCommand() *cobra.Command {
+	exchangeCmd := &cobra.Command{
+		Use:   "exchange <campaign>",
+		Short: "Resume the live corpus exchange of a distributed campaign",
+		Args:  cobra.ExactArgs(1),
+		RunE:  corpusExchangeCommand,
+	}
+	exchangeCmd.Flags().DurationVar(&exchangeInterval, "interval", 5*time.Minute, "Time between corpus exchanges")
+	return exchangeCmd
+}
+
+func corpusExchangeCommand(cmd *cobra.Command, args []string) error {
+	if !isCampaign(args[0]) {
+		return fmt.Errorf("%s is not a distributed campaign", args[0])
+	}
+	rel, err := remoteResultsDir()
+	if err != nil {
+		return err
+	}
+	client, err := connect(currentHost())
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	return client.exchangeLoop(remotePath, args[0], rel, exchangeInterval)
+}
+
+// exchangeLoop moves new corpus entries between the campaign's running
+// instances every interval until none is left running. Interrupting it leaves
+// the instances running.
+func (s *SSHClient) exchangeLoop(remotePath, setID, rel string, interval time.Duration) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	x := &corpusExchange{
+		setID:  setID,
+		rel:    rel,
+		origin: make(map[string]string),
+		paths:  make(map[string]string),
+		has:    make(map[string]map[string]bool),
+	}
+	fmt.Printf("Exchanging %s between instances every %s (Ctrl-C stops the exchange, not the jobs)\n", rel, interval)
+
+	for {
+		select {
+		case <-ctx.Done():
+			fmt.Printf("\nExchange stopped; the instances keep running. Resume with: osiris-lite corpus exchange %s\n", setID)
+			return nil
+		case <-time.After(interval):
+		}
+
+		jobs, err := s.ListJobs(remotePath)
+		if err != nil {
+			fmt.Printf("⚠ %v\n", err)
+			continue
+		}
+		var members []JobState
+		active := 0
+		for _, j := range jobs {
+			if j.Set == setID {
+				members = append(members, j)
+				if j.Status == "running" || j.Status == "queued" {
+					active++
+				}
+			}
+		}
+		if len(members) == 0 {
+			return fmt.Errorf("no jobs found for campaign %s", setID)
+		}
+
+		moved, err := s.exchangeCorpus(remotePath, members, x)
+		if err != nil {
+			fmt.Printf("⚠ %v\n", err)
+		} else {
+			fmt.Printf("[%s] %d unique entries, %d delivered this round, %d/%d instances running\n",
+				time.Now().Format("15:04:05"), len(x.origin), moved, active, len(members))
+		}
+
+		if active == 0 {
+			fmt.Printf("✓ Campaign %s finished\n", setID)
+			return nil
+		}
+	}
+}
+
+// exchangeCorpus lists corpus entries written since the last round in every
+// instance and copies every entry known to the campaign into the running
+// instances that lack it. Entries are compared by SHA-256; a delivery that
+// fails, or an instance that was not running yet, is retried next round. The
+// first round of an exchange lists every entry, so a resumed exchange also
+// delivers what an earlier one missed. It returns the number of files
+// delivered.
+func (s *SSHClient) exchangeCorpus(remotePath string, jobs []JobState, x *corpusExchange) (int, error) {
+	// Files from the last few seconds may still be being written; the next
+	// round picks them up. The listing mark only moves once the instance's
+	// entries have been delivered.
+	var list strings.Builder
+	for _, j := range jobs {
+		fmt.Fprintf(&list, "id=%s; d=%s; mark=%s\n", shellQuote(j.ID), shellQuote(path.Join(j.Workspace, x.rel)), shellQuote(path.Join(jobDir(remotePath, j.ID), "exchange.mark")))
+		if x.scanned {
+			list.WriteString(`if [ -f "$mark" ]; then set -- -newer "$mark"; else set --; fi
+`)
+		} else {
+			list.WriteString("set --\n")
+		}
+		list.WriteString(`if [ -d "$d" ]; then
+  touch -d '5 seconds ago' "$mark.new"
+  (cd "$d" && find . -type f "$@" ! -newer "$mark.new" -exec sha256sum {} + | sed "s|^|$id |")
+fi
+`)
+	}
+	output, err := s.RunCommandWithInput("sh", strings.NewReader(list.String()))
+	if err != nil {
+		return 0, fmt.Errorf("failed to list campaign corpora: %w", err)
+	}
+	x.scanned = true
+
+	for _, j := range jobs {
+		if x.has[j.ID] == nil {
+			x.has[j.ID] = make(map[string]bool)
+		}
+	}
+	var hashes []string
+	for _, line := range strings.Split(output, "\n") {
+		id, rest, ok := strings.Cut(line, " ")
+		if !ok {
+			continue
+		}
+		hash, name, ok := strings.Cut(rest, "  ")
+		if !ok {
+			continue
+		}
+		if x.has[id] == nil {
+			continue
+		}
+		x.has[id][hash] = true
+		if _, known := x.origin[hash]; !known {
+			x.origin[hash] = id
+			x.paths[hash] = strings.TrimPrefix(name, "./")
+		}
+	}
+	for hash := range x.origin {
+		hashes = append(hashes, hash)
+	}
+	sort.Strings(hashes)
+
+	// Group deliveries by source and target instance
+	workspace := make(map[string]string)
+	for _, j := range jobs {
+		workspace[j.ID] = j.Workspace
+	}
+	type route struct{ from, to string }
+	deliveries := make(map[route][]string)
+	for _, hash := range hashes {
+		for _, j := range jobs {
+			if j.Status != "running" || x.has[j.ID][hash] {
+				continue
+			}
+			r := route{x.origin[hash], j.ID}
+			deliveries[r] = append(deliveries[r], hash)
+		}
+	}
+	routes := make([]route, 0, len(deliveries))
+	for r := range deliveries {
+		routes = append(routes, r)
+	}
+	sort.Slice(routes, func(a, b int) bool {
+		return routes[a].from+routes[a].to < routes[b].from+routes[b].to
+	})
+
+	// Containers write their corpus as root, so entries are unpacked inside
+	// the receiving container
+	moved := 0
+	failed := make(map[string]bool)
+	for _, r := range routes {
+		var names bytes.Buffer
+		for _, hash := range deliveries[r] {
+			names.WriteString(x.paths[hash])
+			names.WriteByte(0)
+		}
+		target := path.Join("/app", x.rel)
+		copyCmd := fmt.Sprintf("tar --null -C %s -T - -cf - | docker exec -i %s sh -c %s",
+			shellQuote(path.Join(workspace[r.from], x.rel)), shellQuote(r.to),
+			shellQuote(fmt.Sprintf("mkdir -p %s && tar -xf - -C %s", shellQuote(target), shellQuote(target))))
+		if output, err := s.RunCommandWithInput(copyCmd, &names); err != nil {
+			fmt.Printf("⚠ Failed to deliver %d entries from %s to %s: %s\n", len(deliveries[r]), r.from, r.to, strings.TrimSpace(output))
+			failed[r.from] = true
+			continue
+		}
+		for _, hash := range deliveries[r] {
+			x.has[r.to][hash] = true
+		}
+		moved += len(deliveries[r])
+	}
+
+	// Advance the marks of the instances whose entries all arrived
+	var marks strings.Builder
+	for _, j := range jobs {
+		if !failed[j.ID] {
+			mark := shellQuote(path.Join(jobDir(remotePath, j.ID), "exchange.mark"))
+			fmt.Fprintf(&marks, "[ ! -f %s.new ] || mv %s.new %s\n", mark, mark, mark)
+		}
+	}
+	if marks.Len() > 0 {
+		if output, err := s.RunCommandWithInput("sh", strings.NewReader(marks.String())); err != nil {
+			fmt.Printf("⚠ Failed to advance exchange marks: %s\n", strings.TrimSpace(output))
+		}
+	}
+	x.exchanged += moved
+
+	stats, err := json.Marshal(ExchangeStats{Updated: time.Now().UTC(), Unique: len(x.origin), Exchanged: x.exchanged})
+	if err != nil {
+		return moved, err
+	}
+	statsFile := path.Join(remotePath, stateDir, "sets", x.setID, "exchange.json")
+	if _, err := s.RunCommandWithInput(fmt.Sprintf("cat > %s", shellQuote(statsFile)), bytes.NewReader(stats)); err != nil {
+		return moved, fmt.Errorf("failed to record exchange stats: %w", err)
+	}
+	return moved, nil
+}
+
+// campaignStats reads the last exchange stats of a campaign, if any.
+func (s *SSHClient) campaignStats(remotePath, setID string) (ExchangeStats, bool) {
+	var stats ExchangeStats
+	output, err := s.RunCommand(fmt.Sprintf("cat %s 2>/dev/null", shellQuote(path.Join(remotePath, stateDir, "sets", setID, "exchange.json"))))
+	if err != nil || json.Unmarshal([]byte(output), &stats) != nil {
+		return stats, false
+	}
+	return stats, true
+}
+
+// summarizeCampaigns renders one line per distributed campaign.
+func (s *SSHClient) summarizeCampaigns(remotePath string, jobs []JobState) []string {
+	var order []string
+	counts := make(map[string]map[string]int)
+	for _, j := range jobs {
+		if !isCampaign(j.Set) {
+			continue
+		}
+		if counts[j.Set] == nil {
+			counts[j.Set] = make(map[string]int)
+			order = append(order, j.Set)
+		}
+		status := j.Status
+		if status == "exited" && j.ExitCode != "0" {
+			status = "failed"
+		}
+		counts[j.Set][status]++
+	}
+
+	var lines []string
+	for _, set := range order {
+		c := counts[set]
+		total := 0
+		for _, n := range c {
+			total += n
+		}
+		line := fmt.Sprintf("%s: %d instances (%d running, %d done, %d failed)", set, total, c["running"], c["exited"], c["failed"]+c["lost"])
+		if stats, ok := s.campaignStats(remotePath, set); ok {
+			line += fmt.Sprintf(", %d unique corpus entries, %d exchanged, last exchange %s ago",
+				stats.Unique, stats.Exchanged, time.Since(stats.Updated).Round(time.Second))
+		} else {
+			line += ", no exchange yet"
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
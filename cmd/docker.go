@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// hostUser is the --user value that maps to the remote login user's uid:gid,
+// so files written to bind mounts are not root-owned.
+const hostUser = "host"
+
+var (
+	dockerFlags DockerOptions
+
+	shmSizePattern = regexp.MustCompile(`^[0-9]+[bkmgBKMG]?$`)
+	ulimitPattern  = regexp.MustCompile(`^([a-z]+)=(-?[0-9]+)(:(-?[0-9]+))?$`)
+	networkPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.\-]*$`)
+	userPattern    = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.\-]*(:[A-Za-z0-9_][A-Za-z0-9_.\-]*)?$`)
+
+	knownUlimits = map[string]bool{
+		"core": true, "cpu": true, "data": true, "fsize": true, "locks": true, "memlock": true, "msgqueue": true,
+		"nice": true, "nofile": true, "nproc": true, "rss": true, "rtprio": true, "rttime": true, "sigpending": true, "stack": true,
+	}
+)
+
+// DockerOptions are the extra `docker run` settings from the `docker:` config
+// block and the matching run flags. Every command that starts a container
+// applies them through args so runs behave the same everywhere.
+type DockerOptions struct {
+	Volumes []string `mapstructure:"volumes" json:"volumes,omitempty"`
+	ShmSize string   `mapstructure:"shm-size" json:"shm_size,omitempty"`
+	Ulimits []string `mapstructure:"ulimits" json:"ulimits,omitempty"`
+	Network string   `mapstructure:"network" json:"network,omitempty"`
+	User    string   `mapstructure:"user" json:"user,omitempty"`
+}
+
+// addDockerFlags registers the docker run option flags on a command that
+// starts containers.
+func addDockerFlags(flags *pflag.FlagSet) {
+	flags.StringArrayVar(&dockerFlags.Volumes, "volume", nil, "Extra bind mount or volume as source:target[:options] (repeatable)")
+	flags.StringVar(&dockerFlags.ShmSize, "shm-size", "", "Size of /dev/shm, e.g. 2g")
+	flags.StringArrayVar(&dockerFlags.Ulimits, "ulimit", nil, "Ulimit as name=soft[:hard], e.g. nofile=65536:65536 (repeatable)")
+	flags.StringVar(&dockerFlags.Network, "network", "", "Container network, e.g. none for hermetic runs")
+	flags.StringVar(&dockerFlags.User, "user", "", "Container user as uid[:gid], or 'host' for the remote login user")
+}
+
+// loadDockerOptions merges the config block with flags. Flag volumes and
+// ulimits add to the configured ones; scalar flags replace them.
+func loadDockerOptions() (DockerOptions, error) {
+	var opts DockerOptions
+	if err := viper.UnmarshalKey("docker", &opts); err != nil {
+		return opts, fmt.Errorf("invalid docker config: %w", err)
+	}
+
+	opts.Volumes = append(opts.Volumes, dockerFlags.Volumes...)
+	opts.Ulimits = append(opts.Ulimits, dockerFlags.Ulimits...)
+	if dockerFlags.ShmSize != "" {
+		opts.ShmSize = dockerFlags.ShmSize
+	}
+	if dockerFlags.Network != "" {
+		opts.Network = dockerFlags.Network
+	}
+	if dockerFlags.User != "" {
+		opts.User = dockerFlags.User
+	}
+
+	return opts, opts.validate()
+}
+
+func (o DockerOptions) validate() error {
+	for _, v := range o.Volumes {
+		parts := strings.Split(v, ":")
+		if len(parts) < 2 || len(parts) > 3 || parts[0] == "" {
+			return fmt.Errorf("invalid volume %q: expected source:target[:options]", v)
+		}
+		target := parts[1]
+		if !path.IsAbs(target) {
+			return fmt.Errorf("invalid volume %q: target must be an absolute path", v)
+		}
+		if path.Clean(target) == "/app" {
+			return fmt.Errorf("invalid volume %q: /app is the project mount", v)
+		}
+		if len(parts) == 3 {
+			for _, opt := range strings.Split(parts[2], ",") {
+				switch opt {
+				case "ro", "rw", "z", "Z", "cached", "delegated", "consistent", "nocopy":
+				default:
+					return fmt.Errorf("invalid volume %q: unknown option %q", v, opt)
+				}
+			}
+		}
+	}
+
+	if o.ShmSize != "" && !shmSizePattern.MatchString(o.ShmSize) {
+		return fmt.Errorf("invalid shm-size %q: expected a size like 512m or 2g", o.ShmSize)
+	}
+
+	for _, u := range o.Ulimits {
+		m := ulimitPattern.FindStringSubmatch(u)
+		if m == nil {
+			return fmt.Errorf("invalid ulimit %q: expected name=soft[:hard]", u)
+		}
+		if !knownUlimits[m[1]] {
+			return fmt.Errorf("invalid ulimit %q: unknown limit %q", u, m[1])
+		}
+	}
+
+	if o.Network != "" && !networkPattern.MatchString(o.Network) {
+		return fmt.Errorf("invalid network %q", o.Network)
+	}
+
+	if o.User != "" && o.User != hostUser && !userPattern.MatchString(o.User) {
+		return fmt.Errorf("invalid user %q: expected %s, user, uid or uid:gid", o.User, hostUser)
+	}
+	return nil
+}
+
+// args renders the options as a shell-quoted `docker run` argument string.
+func (o DockerOptions) args() string {
+	var args []string
+	for _, v := range o.Volumes {
+		args = append(args, "-v", shellQuote(v))
+	}
+	if o.ShmSize != "" {
+		args = append(args, "--shm-size", shellQuote(o.ShmSize))
+	}
+	for _, u := range o.Ulimits {
+		args = append(args, "--ulimit", shellQuote(u))
+	}
+	if o.Network != "" {
+		args = append(args, "--network", shellQuote(o.Network))
+	}
+	switch o.User {
+	case "":
+	case hostUser:
+		// Resolved by the remote shell when the container starts
+		args = append(args, "--user", `"$(id -u):$(id -g)"`)
+	default:
+		args = append(args, "--user", shellQuote(o.User))
+	}
+	return strings.Join(args, " ")
+}
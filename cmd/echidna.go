@@ -0,0 +1,239 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// echidnaConfigFile is the config run --fuzzer echidna passes to Echidna.
+const echidnaConfigFile = "echidna.yaml"
+
+// The log lines status needs: the per-test lines of the final report or a
+// --format json report, and the latest periodic status line.
+const (
+	echidnaProgress = `^[^ [][^ ]*: (passing|passed|failed|fuzzing|shrinking|could not evaluate)|^[{]"`
+	echidnaLatest   = `\[status\] tests:`
+)
+
+var (
+	echidnaTimestamp = regexp.MustCompile(`^\[(\d{4}-\d\d-\d\d \d\d:\d\d:\d\d(?:\.\d+)?)\]`)
+	echidnaStatus    = regexp.MustCompile(`\[status\] tests: (\d+)/(\d+),.*cov: (\d+), corpus: (\d+)`)
+	echidnaCoverage  = regexp.MustCompile(`New coverage: (\d+) instr, \d+ contracts, (\d+) seqs in corpus`)
+	echidnaTest      = regexp.MustCompile(`^([^\s\[][^\s]*): (passing|passed|failed|fuzzing|shrinking|could not evaluate)`)
+	echidnaTotals    = regexp.MustCompile(`^(Unique instructions|Unique codehashes|Corpus size|Total calls): (\d+)`)
+	echidnaCorpusIn  = regexp.MustCompile(`--corpus-dir[= ](\S+)`)
+	echidnaCommand   = regexp.MustCompile(`\bechidna\b`)
+)
+
+type echidnaAdapter struct{}
+
+func (echidnaAdapter) Name() string { return "echidna" }
+
+func (echidnaAdapter) Spec() AdapterSpec {
+	return AdapterSpec{
+		Command:    "echidna . --config {{config}} {{args}}",
+		Config:     echidnaConfigFile,
+		StopSignal: "SIGINT",
+		Progress:   echidnaProgress,
+		Latest:     echidnaLatest,
+	}
+}
+
+func (echidnaAdapter) Matches(command string) bool {
+	return echidnaCommand.MatchString(command)
+}
+
+// Prepare records the config the command passes with --config and the corpus
+// directory it sets, so pull <job> fetches the corpus and seeds land in it.
+// A command without --config, such as make echidna, is taken to use
+// echidna.yaml when the project has one.
+func (a echidnaAdapter) Prepare(root, command string, env jobEnv) (*FuzzerSettings, string, error) {
+	if err := checkOverrides(a.Name()); err != nil {
+		return nil, "", err
+	}
+	settings := newSettings(a)
+	if m := configFlagPattern.FindStringSubmatch(command); m != nil {
+		settings.Config = strings.Trim(m[1], `'"`)
+	} else if _, err := os.Stat(filepath.Join(root, echidnaConfigFile)); err != nil {
+		settings.Config = ""
+	}
+
+	if settings.Config != "" {
+		v := viper.New()
+		v.SetConfigFile(filepath.Join(root, filepath.FromSlash(settings.Config)))
+		v.SetConfigType("yaml")
+		if err := v.ReadInConfig(); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, "", fmt.Errorf("failed to read %s: %w", settings.Config, err)
+		}
+		settings.Corpus = v.GetString("corpusDir")
+	}
+	if m := echidnaCorpusIn.FindStringSubmatch(command); m != nil {
+		settings.Corpus = strings.Trim(m[1], `'"`)
+	}
+	return settings, command, nil
+}
+
+func (echidnaAdapter) Parse(r io.Reader) (*Results, error) {
+	return parseEchidna(r)
+}
+
+// echidnaReport is the --format json output of Echidna.
+type echidnaReport struct {
+	Success bool    `json:"success"`
+	Error   *string `json:"error"`
+	Tests   []struct {
+		Contract     string  `json:"contract"`
+		Name         string  `json:"name"`
+		Status       string  `json:"status"`
+		Error        *string `json:"error"`
+		TestType     string  `json:"testType"`
+		Transactions []struct {
+			Contract  string   `json:"contract"`
+			Function  string   `json:"function"`
+			Arguments []string `json:"arguments"`
+		} `json:"transactions"`
+	} `json:"tests"`
+}
+
+// parseEchidna reads Echidna's text output, or the report of --format json,
+// into results. It also accepts a partial log, such as the lines selected by
+// echidnaProgress, while the campaign is still running.
+func parseEchidna(r io.Reader) (*Results, error) {
+	results := &Results{Fuzzer: "echidna", CoverageUnit: "unique instructions"}
+	var current *TestResult
+	inSequence := false
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		// Call sequences and errors are indented under their test
+		if current != nil && strings.HasPrefix(line, "  ") {
+			trimmed := strings.TrimSpace(line)
+			switch {
+			case strings.HasPrefix(trimmed, "Call sequence"):
+				inSequence = true
+			case inSequence && strings.HasPrefix(line, "    ") && trimmed != "":
+				current.Sequence = append(current.Sequence, trimmed)
+			case current.Status == "error" && current.Error == "":
+				current.Error = trimmed
+			default:
+				inSequence = false
+			}
+			continue
+		}
+		current, inSequence = nil, false
+
+		if strings.HasPrefix(line, "{\"") {
+			if report, ok := parseEchidnaJSON(line); ok {
+				results.Tests = report.Tests
+			}
+			continue
+		}
+		if m := echidnaTest.FindStringSubmatch(line); m != nil {
+			results.Tests = append(results.Tests, TestResult{Name: m[1], Status: echidnaOutcome(m[2])})
+			current = &results.Tests[len(results.Tests)-1]
+			continue
+		}
+		if m := echidnaTotals.FindStringSubmatch(line); m != nil {
+			n, _ := strconv.Atoi(m[2])
+			switch m[1] {
+			case "Unique instructions":
+				results.Coverage = n
+			case "Unique codehashes":
+				results.Codehashes = n
+			case "Corpus size":
+				results.CorpusSize = n
+			case "Total calls":
+				results.Calls = n
+			}
+			continue
+		}
+
+		var at time.Time
+		if m := echidnaTimestamp.FindStringSubmatch(line); m != nil {
+			at, _ = time.Parse("2006-01-02 15:04:05", m[1])
+		}
+		if m := echidnaStatus.FindStringSubmatch(line); m != nil {
+			results.Broken, _ = strconv.Atoi(m[1])
+			results.Total, _ = strconv.Atoi(m[2])
+			cov, _ := strconv.Atoi(m[3])
+			corpus, _ := strconv.Atoi(m[4])
+			results.observe(CoveragePoint{Time: at, Coverage: cov, Corpus: corpus})
+		} else if m := echidnaCoverage.FindStringSubmatch(line); m != nil {
+			cov, _ := strconv.Atoi(m[1])
+			corpus, _ := strconv.Atoi(m[2])
+			results.observe(CoveragePoint{Time: at, Coverage: cov, Corpus: corpus})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read Echidna output: %w", err)
+	}
+
+	// The final report is authoritative over the last status line
+	if len(results.Tests) > 0 {
+		results.Broken, results.Total = 0, len(results.Tests)
+		for _, t := range results.Tests {
+			if t.Status == "failed" {
+				results.Broken++
+			}
+		}
+	}
+	return results, nil
+}
+
+// parseEchidnaJSON converts a --format json report into test results,
+// leaving out optimization tests, which have no pass or fail outcome.
+func parseEchidnaJSON(line string) (*Results, bool) {
+	var report echidnaReport
+	if json.Unmarshal([]byte(line), &report) != nil || report.Tests == nil {
+		return nil, false
+	}
+	results := &Results{}
+	for _, t := range report.Tests {
+		if t.TestType == "optimization" || t.TestType == "exploration" {
+			continue
+		}
+		test := TestResult{Name: t.Name, Status: echidnaOutcome(t.Status)}
+		if t.Error != nil {
+			test.Error = *t.Error
+		}
+		for _, tx := range t.Transactions {
+			call := tx.Function
+			if !strings.Contains(call, "(") {
+				call += "(" + strings.Join(tx.Arguments, ", ") + ")"
+			}
+			if tx.Contract != "" {
+				call = tx.Contract + "." + call
+			}
+			test.Sequence = append(test.Sequence, call)
+		}
+		results.Tests = append(results.Tests, test)
+	}
+	return results, true
+}
+
+// echidnaOutcome maps Echidna's text and JSON test states to passed, failed
+// or error. Tests still being fuzzed have not been broken yet.
+func echidnaOutcome(state string) string {
+	switch state {
+	case "failed", "shrinking", "solved":
+		return "failed"
+	case "could not evaluate", "error":
+		return "error"
+	default:
+		return "passed"
+	}
+}
@@ -0,0 +1,53 @@
+package cmd
+
+import "testing"
+
+func TestParseEchidna(t *testing.T) {
+	tests := []struct {
+		name    string
+		fixture string
+		want    Results
+		points  int
+	}{
+		{
+			name:    "final text report",
+			fixture: "echidna/text.log",
+			want: Results{
+				Fuzzer: "echidna", Broken: 1, Total: 4,
+				Tests: []TestResult{
+					{Name: "echidna_balance_under_cap", Status: "failed", Sequence: []string{
+						"Tester.deposit(500000000000000000000001) from: 0x0000000000000000000000000000000000010000 Time delay: 1 seconds Block delay: 1",
+						"Tester.deposit(500000000000000000000000) from: 0x0000000000000000000000000000000000020000",
+					}},
+					{Name: "echidna_total_supply", Status: "passed"},
+					{Name: "assert_withdraw_accounting(uint256)", Status: "passed"},
+					{Name: "echidna_no_revert", Status: "error", Error: "Error: VM failed with Revert"},
+				},
+				Coverage: 5012, Codehashes: 3, CorpusSize: 17, Calls: 50048,
+			},
+			points: 3,
+		},
+		{
+			name:    "json report",
+			fixture: "echidna/json.log",
+			want: Results{
+				Fuzzer: "echidna", Broken: 1, Total: 2,
+				Tests: []TestResult{
+					{Name: "echidna_balance_under_cap", Status: "failed", Sequence: []string{"Tester.deposit(1000000000000000000000001)"}},
+					{Name: "assert_withdraw_accounting(uint256)", Status: "passed"},
+				},
+			},
+		},
+		{
+			name:    "status line while running",
+			fixture: "echidna/progress.log",
+			want:    Results{Fuzzer: "echidna", Broken: 1, Total: 3, Coverage: 5012, CorpusSize: 17},
+			points:  1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkResults(t, parseFixture(t, parseEchidna, tt.fixture), &tt.want, tt.points)
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/joho/godotenv"
+	"github.com/spf13/viper"
+)
+
+var (
+	envFlags    []string
+	envFiles    []string
+	envKeyRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+	// secretEnvPattern flags variable names whose values are treated as
+	// secrets even when passed with --env or the config env map.
+	secretEnvPattern = regexp.MustCompile(`(?i)(KEY|TOKEN|SECRET|PASS|PRIVATE|MNEMONIC|AUTH|CREDENTIAL|RPC)`)
+
+	// secretValues are masked wherever osiris-lite prints or records commands.
+	secretValues []string
+)
+
+// jobEnv is the environment passed into a job's container. Values never touch
+// the remote disk: they travel over the SSH session's stdin into the shell
+// that starts the job, and docker reads them from there with -e NAME.
+type jobEnv struct {
+	values map[string]string
+	secret map[string]bool
+}
+
+// resolveEnv merges the config env map, --env-file files and --env flags, in
+// increasing precedence. Values from env files are always secret.
+func resolveEnv() (jobEnv, error) {
+	env := jobEnv{values: make(map[string]string), secret: make(map[string]bool)}
+
+	// Viper lowercases keys, so config env names are upper-cased
+	for key, value := range viper.GetStringMapString("env") {
+		if err := env.set(strings.ToUpper(key), value, false); err != nil {
+			return env, fmt.Errorf("config env: %w", err)
+		}
+	}
+
+	for _, file := range envFiles {
+		values, err := godotenv.Read(expandPath(file))
+		if err != nil {
+			return env, fmt.Errorf("failed to read env file %s: %w", file, err)
+		}
+		for key, value := range values {
+			if err := env.set(key, value, true); err != nil {
+				return env, fmt.Errorf("env file %s: %w", file, err)
+			}
+		}
+	}
+
+	for _, pair := range envFlags {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return env, fmt.Errorf("invalid --env %q: expected KEY=VALUE", pair)
+		}
+		if err := env.set(key, value, false); err != nil {
+			return env, err
+		}
+	}
+
+	for key, value := range env.values {
+		if env.secret[key] && value != "" {
+			secretValues = append(secretValues, value)
+		}
+	}
+	return env, nil
+}
+
+func (e jobEnv) set(key, value string, secret bool) error {
+	if !envKeyRegex.MatchString(key) {
+		return fmt.Errorf("invalid environment variable name %q", key)
+	}
+	e.values[key] = value
+	e.secret[key] = secret || secretEnvPattern.MatchString(key)
+	return nil
+}
+
+// names returns the variable names in a stable order.
+func (e jobEnv) names() []string {
+	names := make([]string, 0, len(e.values))
+	for name := range e.values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// masked returns the environment as recorded in job metadata.
+func (e jobEnv) masked() map[string]string {
+	if len(e.values) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(e.values))
+	for key, value := range e.values {
+		if e.secret[key] {
+			value = maskSecret(value)
+		}
+		out[key] = value
+	}
+	return out
+}
+
+// exports renders the environment as sh export statements, to be fed over
+// stdin and eval'd by the remote shell.
+func (e jobEnv) exports() string {
+	var b strings.Builder
+	for _, name := range e.names() {
+		fmt.Fprintf(&b, "export %s=%s\n", name, shellQuote(e.values[name]))
+	}
+	return b.String()
+}
+
+// withEnv wraps a remote command so it first evals export statements read
+// from stdin. Pair it with exports() as the session input.
+func withEnv(command string) string {
+	return fmt.Sprintf("sh -c %s", shellQuote(`eval "$(cat)" && `+command))
+}
+
+// minMaskedLength is the shortest secret value maskSecrets replaces. Shorter
+// values such as "1" or "true" would garble every command they appear in.
+const minMaskedLength = 4
+
+// maskSecrets replaces every known secret value in s.
+func maskSecrets(s string) string {
+	for _, secret := range secretValues {
+		if len(secret) < minMaskedLength {
+			continue
+		}
+		s = strings.ReplaceAll(s, secret, "********")
+	}
+	return s
+}
@@ -0,0 +1,230 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// foundryInvariantPrefix selects invariant tests when the command does not.
+const foundryInvariantPrefix = "invariant_"
+
+// foundryConfigFile holds the invariant settings run's flags override.
+const foundryConfigFile = "foundry.toml"
+
+// foundryProgress selects the report forge test --json prints once all
+// suites have run; there is nothing to read before that.
+const foundryProgress = `^[{]"`
+
+var (
+	forgeTestCommand = regexp.MustCompile(`\bforge\s+test\b`)
+	forgeMatchTest   = regexp.MustCompile(`(^|\s)(--mt|--match-test)(\s|=)`)
+)
+
+// forgeSuite is one test contract in the output of forge test --json.
+type forgeSuite struct {
+	TestResults map[string]struct {
+		Status         string                     `json:"status"`
+		Reason         *string                    `json:"reason"`
+		Counterexample json.RawMessage            `json:"counterexample"`
+		Kind           map[string]json.RawMessage `json:"kind"`
+	} `json:"test_results"`
+}
+
+// forgeInvariantKind holds the statistics forge reports for an invariant test.
+type forgeInvariantKind struct {
+	Runs  int `json:"runs"`
+	Calls int `json:"calls"`
+}
+
+// forgeCall is one call of a counterexample sequence.
+type forgeCall struct {
+	Sender       string `json:"sender"`
+	ContractName string `json:"contract_name"`
+	FuncName     string `json:"func_name"`
+	Signature    string `json:"signature"`
+	Args         string `json:"args"`
+	Calldata     string `json:"calldata"`
+}
+
+type foundryAdapter struct{}
+
+func (foundryAdapter) Name() string { return "foundry" }
+
+func (foundryAdapter) Spec() AdapterSpec {
+	return AdapterSpec{
+		Command:    "forge test {{args}}",
+		Config:     foundryConfigFile,
+		StopSignal: "SIGINT",
+		Progress:   foundryProgress,
+	}
+}
+
+func (foundryAdapter) Matches(command string) bool {
+	return forgeTestCommand.MatchString(command)
+}
+
+func (foundryAdapter) Parse(r io.Reader) (*Results, error) {
+	return parseFoundry(r)
+}
+
+// Prepare applies the run --runs, --depth and --fail-on-revert overrides
+// through Foundry's FOUNDRY_INVARIANT_* environment variables. The forge test
+// call gains --json for result parsing and, unless the command selects tests
+// itself, --mt invariant_.
+func (a foundryAdapter) Prepare(root, command string, env jobEnv) (*FuzzerSettings, string, error) {
+	if err := checkOverrides(a.Name(), "runs", "depth", "fail-on-revert"); err != nil {
+		return nil, "", err
+	}
+	settings := newSettings(a)
+	settings.Runs, settings.Depth, settings.FailOnRevert = fuzzRuns, fuzzDepth, fuzzFailOnRevert
+	if fuzzRuns > 0 {
+		if err := env.set("FOUNDRY_INVARIANT_RUNS", strconv.Itoa(fuzzRuns), false); err != nil {
+			return nil, "", err
+		}
+	}
+	if fuzzDepth > 0 {
+		if err := env.set("FOUNDRY_INVARIANT_DEPTH", strconv.Itoa(fuzzDepth), false); err != nil {
+			return nil, "", err
+		}
+	}
+	if fuzzFailOnRevert != nil {
+		if err := env.set("FOUNDRY_INVARIANT_FAIL_ON_REVERT", strconv.FormatBool(*fuzzFailOnRevert), false); err != nil {
+			return nil, "", err
+		}
+	}
+
+	loc := forgeTestCommand.FindStringIndex(command)
+	if loc == nil {
+		return settings, command, nil
+	}
+	var flags []string
+	if !forgeMatchTest.MatchString(command) {
+		flags = append(flags, "--mt", foundryInvariantPrefix)
+	}
+	if !strings.Contains(command, "--json") {
+		flags = append(flags, "--json")
+	}
+	if len(flags) == 0 {
+		return settings, command, nil
+	}
+	return settings, command[:loc[1]] + " " + strings.Join(flags, " ") + command[loc[1]:], nil
+}
+
+// parseFoundry reads the report of forge test --json into one result per
+// invariant test, with the counterexample sequence of every broken one.
+// Fuzz and unit tests in the same report are left out.
+func parseFoundry(r io.Reader) (*Results, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read forge output: %w", err)
+	}
+	results := &Results{Fuzzer: "foundry"}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		if !strings.HasPrefix(line, "{\"") {
+			continue
+		}
+		var suites map[string]forgeSuite
+		if json.Unmarshal([]byte(line), &suites) != nil {
+			continue
+		}
+		results.Tests = nil
+		for _, suite := range sortedSuites(suites) {
+			_, contract, _ := strings.Cut(suite, ":")
+			tests := suites[suite].TestResults
+			names := make([]string, 0, len(tests))
+			for name := range tests {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+
+			for _, name := range names {
+				t := tests[name]
+				raw, invariant := t.Kind["Invariant"]
+				if (!invariant && !strings.HasPrefix(name, "invariant")) || t.Status == "Skipped" {
+					continue
+				}
+				var kind forgeInvariantKind
+				json.Unmarshal(raw, &kind)
+				results.Calls += kind.Calls
+
+				test := TestResult{Name: contract + "." + name, Status: "passed"}
+				if t.Status != "Success" {
+					test.Status = "failed"
+					test.Sequence = forgeSequence(t.Counterexample)
+				}
+				if t.Reason != nil {
+					test.Error = *t.Reason
+				}
+				results.Tests = append(results.Tests, test)
+			}
+		}
+	}
+
+	results.Total = len(results.Tests)
+	for _, t := range results.Tests {
+		if t.Status == "failed" {
+			results.Broken++
+		}
+	}
+	return results, nil
+}
+
+func sortedSuites(suites map[string]forgeSuite) []string {
+	keys := make([]string, 0, len(suites))
+	for k := range suites {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// forgeSequence renders a counterexample, {"Sequence": [...]} or, in newer
+// forge versions, {"Sequence": [original length, [...]]}.
+func forgeSequence(raw json.RawMessage) []string {
+	var tagged struct {
+		Sequence json.RawMessage `json:"Sequence"`
+		Single   *forgeCall      `json:"Single"`
+	}
+	if len(raw) == 0 || json.Unmarshal(raw, &tagged) != nil {
+		return nil
+	}
+	var calls []forgeCall
+	if tagged.Single != nil {
+		calls = append(calls, *tagged.Single)
+	} else if json.Unmarshal(tagged.Sequence, &calls) != nil {
+		var shrunk []json.RawMessage
+		if json.Unmarshal(tagged.Sequence, &shrunk) != nil || len(shrunk) != 2 || json.Unmarshal(shrunk[1], &calls) != nil {
+			return nil
+		}
+	}
+
+	var sequence []string
+	for _, c := range calls {
+		name := c.FuncName
+		if name == "" {
+			name, _, _ = strings.Cut(c.Signature, "(")
+		}
+		call := c.Calldata
+		if name != "" {
+			call = name + "(" + c.Args + ")"
+		}
+		if c.ContractName != "" {
+			_, contract, found := strings.Cut(c.ContractName, ":")
+			if !found {
+				contract = c.ContractName
+			}
+			call = contract + "." + call
+		}
+		if c.Sender != "" {
+			call += " from " + c.Sender
+		}
+		sequence = append(sequence, call)
+	}
+	return sequence
+}
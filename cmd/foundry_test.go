@@ -0,0 +1,45 @@
+package cmd
+
+import "testing"
+
+func TestParseFoundry(t *testing.T) {
+	tests := []struct {
+		name    string
+		fixture string
+		want    Results
+	}{
+		{
+			name:    "invariant report",
+			fixture: "foundry/invariant.json",
+			want: Results{
+				Fuzzer: "foundry", Broken: 1, Total: 2,
+				Tests: []TestResult{
+					{Name: "VaultInvariants.invariant_solvency()", Status: "failed", Error: "insolvent", Sequence: []string{
+						"Handler.deposit(5) from 0x0000000000000000000000000000000000010000",
+						"Handler.withdraw(6) from 0x0000000000000000000000000000000000020000",
+					}},
+					{Name: "VaultInvariants.invariant_totalSupply()", Status: "passed"},
+				},
+				Calls: 65536,
+			},
+		},
+		{
+			name:    "shrunk counterexample",
+			fixture: "foundry/shrunk.json",
+			want: Results{
+				Fuzzer: "foundry", Broken: 1, Total: 1,
+				Tests: []TestResult{
+					{Name: "VaultInvariants.invariant_solvency()", Status: "failed", Error: "insolvent", Sequence: []string{
+						"Handler.withdraw(6) from 0x0000000000000000000000000000000000020000",
+					}},
+				},
+				Calls: 384,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkResults(t, parseFixture(t, parseFoundry, tt.fixture), &tt.want, 0)
+		})
+	}
+}
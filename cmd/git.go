@@ -0,0 +1,171 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+var runRef string
+
+// GitInfo records which code version a job ran. Without --ref it describes the
+// working tree that was synced, including whether it had uncommitted changes.
+type GitInfo struct {
+	Commit    string   `json:"commit"`
+	Ref       string   `json:"ref,omitempty"`
+	Dirty     bool     `json:"dirty,omitempty"`
+	Untracked []string `json:"untracked,omitempty"`
+
+	// diff is the uncommitted diff against Commit, stored next to the job
+	diff string
+}
+
+func git(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %s", args[0], strings.TrimSpace(stderr.String()))
+	}
+	return string(out), nil
+}
+
+// workingTreeInfo describes the current directory's git state. It returns nil
+// outside a git repository.
+func workingTreeInfo() (*GitInfo, error) {
+	if _, err := git(".", "rev-parse", "--is-inside-work-tree"); err != nil {
+		return nil, nil
+	}
+	commit, err := git(".", "rev-parse", "HEAD")
+	if err != nil {
+		// A repository without commits has nothing to record
+		return nil, nil
+	}
+	info := &GitInfo{Commit: strings.TrimSpace(commit)}
+
+	status, err := git(".", "status", "--porcelain", "--untracked-files=all", ".")
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range strings.Split(strings.TrimRight(status, "\n"), "\n") {
+		if line == "" {
+			continue
+		}
+		info.Dirty = true
+		if strings.HasPrefix(line, "?? ") {
+			info.Untracked = append(info.Untracked, strings.TrimPrefix(line, "?? "))
+		}
+	}
+
+	if info.Dirty {
+		if info.diff, err = git(".", "diff", "HEAD", "--binary", "--", "."); err != nil {
+			return nil, err
+		}
+	}
+	return info, nil
+}
+
+// exportRef writes a clean checkout of ref, including submodules, to a
+// temporary directory. It returns the directory matching the current working
+// directory inside that checkout; remove the returned root when done.
+func exportRef(ref string) (root, dir string, info *GitInfo, err error) {
+	top, err := git(".", "rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", "", nil, fmt.Errorf("--ref needs a git repository: %w", err)
+	}
+	top = strings.TrimSpace(top)
+	prefix, err := git(".", "rev-parse", "--show-prefix")
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	commit, err := git(top, "rev-parse", "--verify", "--end-of-options", ref+"^{commit}")
+	if err != nil {
+		return "", "", nil, fmt.Errorf("unknown ref %q: %w", ref, err)
+	}
+	commit = strings.TrimSpace(commit)
+
+	root, err = os.MkdirTemp("", "osiris-ref-*")
+	if err != nil {
+		return "", "", nil, fmt.Errorf("failed to create checkout directory: %w", err)
+	}
+	if err := exportCommit(top, commit, root); err != nil {
+		os.RemoveAll(root)
+		return "", "", nil, err
+	}
+
+	dir = filepath.Join(root, filepath.FromSlash(strings.TrimSpace(prefix)))
+	if _, err := os.Stat(dir); err != nil {
+		os.RemoveAll(root)
+		return "", "", nil, fmt.Errorf("current directory does not exist at %s", ref)
+	}
+	return root, dir, &GitInfo{Commit: commit, Ref: ref}, nil
+}
+
+// exportCommit extracts commit of the repository at repo into dest with
+// `git archive`, then recurses into submodules at the commits it pins.
+func exportCommit(repo, commit, dest string) error {
+	archive := exec.Command("git", "-C", repo, "archive", "--format=tar", commit)
+	extract := exec.Command("tar", "-x", "-C", dest)
+	pipe, err := archive.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	extract.Stdin = pipe
+	var stderr bytes.Buffer
+	archive.Stderr = &stderr
+	extract.Stderr = &stderr
+	if err := extract.Start(); err != nil {
+		return fmt.Errorf("failed to start tar: %w", err)
+	}
+	if err := archive.Run(); err != nil {
+		extract.Wait()
+		return fmt.Errorf("failed to export %s: %s", commit, strings.TrimSpace(stderr.String()))
+	}
+	if err := extract.Wait(); err != nil {
+		return fmt.Errorf("failed to extract %s: %s", commit, strings.TrimSpace(stderr.String()))
+	}
+
+	// Submodules are tree entries with mode 160000 pointing at a commit
+	tree, err := git(repo, "ls-tree", "-r", "-z", commit)
+	if err != nil {
+		return err
+	}
+	for _, entry := range strings.Split(tree, "\x00") {
+		meta, path, ok := strings.Cut(entry, "\t")
+		fields := strings.Fields(meta)
+		if !ok || len(fields) != 3 || fields[0] != "160000" {
+			continue
+		}
+		sub, subCommit := filepath.Join(repo, path), fields[2]
+		if _, err := os.Stat(filepath.Join(sub, ".git")); err != nil {
+			return fmt.Errorf("submodule %s is not initialized; run git submodule update --init --recursive", path)
+		}
+		if _, err := git(sub, "cat-file", "-e", subCommit+"^{commit}"); err != nil {
+			return fmt.Errorf("submodule %s at %s is not available locally; run git submodule update --init --recursive", path, subCommit[:12])
+		}
+		target := filepath.Join(dest, path)
+		if err := os.MkdirAll(target, 0755); err != nil {
+			return err
+		}
+		if err := exportCommit(sub, subCommit, target); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// describe renders the info as a short commit, marked with * when dirty.
+func (g *GitInfo) describe() string {
+	if g == nil {
+		return "-"
+	}
+	if g.Dirty {
+		return g.Commit[:12] + "*"
+	}
+	return g.Commit[:12]
+}
@@ -0,0 +1,239 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Host is one fuzzing server. Entries come from the `hosts:` config section;
+// the top-level remote settings form an implicit default host.
+type Host struct {
+	Name       string   `mapstructure:"-"`
+	Remote     string   `mapstructure:"remote"`
+	RemotePath string   `mapstructure:"remote-path"`
+	Tags       []string `mapstructure:"tags"`
+	Capacity   int      `mapstructure:"capacity"`
+	Password   string   `mapstructure:"password"`
+}
+
+// HostStats is a snapshot of a host's resources and osiris load.
+type HostStats struct {
+	Cores       int
+	Load1       float64
+	MemTotalKB  int64
+	MemAvailKB  int64
+	DiskTotalKB int64
+	DiskAvailKB int64
+	RunningJobs int
+}
+
+// loadHosts reads the `hosts:` section, filling unset fields from the
+// top-level settings so a host entry only needs what differs.
+func loadHosts() (map[string]Host, error) {
+	raw := make(map[string]Host)
+	if err := viper.UnmarshalKey("hosts", &raw); err != nil {
+		return nil, fmt.Errorf("invalid hosts config: %w", err)
+	}
+
+	fleet := make(map[string]Host, len(raw))
+	for name, h := range raw {
+		if strings.Contains(name, ":") {
+			return nil, fmt.Errorf("host name %q must not contain ':'", name)
+		}
+		h.Name = name
+		if h.Remote == "" {
+			h.Remote = name
+		}
+		if h.RemotePath == "" {
+			h.RemotePath = remotePath
+		}
+		if h.Capacity == 0 {
+			h.Capacity = capacity
+		}
+		if h.Password == "" {
+			h.Password = password
+		}
+		fleet[name] = h
+	}
+	return fleet, nil
+}
+
+// currentHost is the host selected by --host, or the top-level remote settings.
+func currentHost() Host {
+	name := hostName
+	if name == "" {
+		name = remote
+	}
+	return Host{
+		Name:       name,
+		Remote:     remote,
+		RemotePath: remotePath,
+		Capacity:   capacity,
+		Password:   password,
+	}
+}
+
+func lookupHost(name string) (Host, error) {
+	if h, ok := hosts[name]; ok {
+		return h, nil
+	}
+	return Host{}, fmt.Errorf("unknown host %q (not in hosts config)", name)
+}
+
+// fleetHosts returns every configured host sorted by name, or the current
+// host alone when no fleet is configured.
+func fleetHosts() []Host {
+	if len(hosts) == 0 {
+		return []Host{currentHost()}
+	}
+	var fleet []Host
+	for _, h := range hosts {
+		fleet = append(fleet, h)
+	}
+	sort.Slice(fleet, func(a, b int) bool { return fleet[a].Name < fleet[b].Name })
+	return fleet
+}
+
+// parseAddress resolves a "host:job" argument whose prefix is a configured host.
+func parseAddress(arg string) (Host, string, bool) {
+	if name, job, ok := strings.Cut(arg, ":"); ok {
+		if h, err := lookupHost(name); err == nil {
+			return h, job, true
+		}
+	}
+	return Host{}, "", false
+}
+
+// splitAddress is parseAddress falling back to the current host.
+func splitAddress(arg string) (Host, string) {
+	if h, job, ok := parseAddress(arg); ok {
+		return h, job
+	}
+	return currentHost(), arg
+}
+
+func connect(h Host) (*SSHClient, error) {
+	if h.Remote == "" {
+		if isHostSelector(hostName) {
+			return nil, fmt.Errorf("--host %s is only supported by run", hostName)
+		}
+		return nil, fmt.Errorf("no remote configured (set remote, --remote or --host)")
+	}
+
+	var client *SSHClient
+	var err error
+
+	if h.Password != "" {
+		client, err = NewSSHClientWithPassword(h.Remote, h.Password)
+	} else {
+		client, err = NewSSHClient(h.Remote)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", h.Remote, err)
+	}
+	return client, nil
+}
+
+// hostResult carries the outcome of a per-host operation run by forEachHost.
+type hostResult[T any] struct {
+	Host  Host
+	Value T
+	Err   error
+}
+
+// forEachHost runs fn against every host in parallel, each on its own
+// connection, and gives up on a host once timeout elapses.
+func forEachHost[T any](fleet []Host, timeout time.Duration, fn func(Host, *SSHClient) (T, error)) []hostResult[T] {
+	results := make([]hostResult[T], len(fleet))
+	done := make(chan int, len(fleet))
+
+	for i, h := range fleet {
+		results[i].Host = h
+		go func(i int, h Host) {
+			ch := make(chan hostResult[T], 1)
+			go func() {
+				client, err := connect(h)
+				if err != nil {
+					ch <- hostResult[T]{Host: h, Err: err}
+					return
+				}
+				defer client.Close()
+				value, err := fn(h, client)
+				ch <- hostResult[T]{Host: h, Value: value, Err: err}
+			}()
+
+			select {
+			case r := <-ch:
+				results[i] = r
+			case <-time.After(timeout):
+				results[i].Err = fmt.Errorf("timed out after %s", timeout)
+			}
+			done <- i
+		}(i, h)
+	}
+
+	for range fleet {
+		<-done
+	}
+	return results
+}
+
+// Probe collects core count, load, memory, disk headroom at remote-path and
+// the number of running osiris jobs in a single round trip.
+func (s *SSHClient) Probe(remotePath string) (HostStats, error) {
+	probeCmd := fmt.Sprintf(`echo cores=$(nproc)
+echo load=$(cut -d' ' -f1 /proc/loadavg)
+echo mem=$(awk '/^MemTotal:/{t=$2} /^MemAvailable:/{a=$2} END{print t, a}' /proc/meminfo)
+echo disk=$( (df -Pk %s 2>/dev/null || df -Pk /) | tail -1 | awk '{print $2, $4}')
+echo jobs=$(docker ps -q --filter label=osiris.job 2>/dev/null | wc -l)`, shellQuote(remotePath))
+	output, err := s.RunCommand(probeCmd)
+	if err != nil {
+		return HostStats{}, fmt.Errorf("failed to probe host: %w", err)
+	}
+
+	var stats HostStats
+	for _, line := range strings.Split(output, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(value)
+		switch key {
+		case "cores":
+			stats.Cores, _ = strconv.Atoi(value)
+		case "load":
+			stats.Load1, _ = strconv.ParseFloat(value, 64)
+		case "mem":
+			if len(fields) == 2 {
+				stats.MemTotalKB, _ = strconv.ParseInt(fields[0], 10, 64)
+				stats.MemAvailKB, _ = strconv.ParseInt(fields[1], 10, 64)
+			}
+		case "disk":
+			if len(fields) == 2 {
+				stats.DiskTotalKB, _ = strconv.ParseInt(fields[0], 10, 64)
+				stats.DiskAvailKB, _ = strconv.ParseInt(fields[1], 10, 64)
+			}
+		case "jobs":
+			stats.RunningJobs, _ = strconv.Atoi(value)
+		}
+	}
+	return stats, nil
+}
+
+// formatKB renders a kilobyte count with a binary unit suffix.
+func formatKB(kb int64) string {
+	units := []string{"K", "M", "G", "T"}
+	value := float64(kb)
+	i := 0
+	for value >= 1024 && i < len(units)-1 {
+		value /= 1024
+		i++
+	}
+	return fmt.Sprintf("%.1f%s", value, units[i])
+}
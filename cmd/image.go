@@ -0,0 +1,245 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// contextTagPrefix marks image tags derived from the build context hash.
+const contextTagPrefix = "ctx-"
+
+var rebuild bool
+
+// contextImage returns the image reference for the build context at root: the
+// configured image tagged with a hash of the Dockerfile, every file it copies
+// in and the build options. An unchanged context maps to the same tag, so the
+// remote can skip the build when that tag already exists.
+func contextImage(root string, opts BuildOptions, syncOpts SyncOptions) (string, error) {
+	hash, err := contextHash(root, dockerfilePath, opts, syncOpts)
+	if err != nil {
+		return "", err
+	}
+	return imageName(image) + ":" + contextTagPrefix + hash[:12], nil
+}
+
+// imageName strips the tag from an image reference.
+func imageName(ref string) string {
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		return ref[:i]
+	}
+	return ref
+}
+
+// contextHash hashes the build options, the Dockerfile, .dockerignore and the
+// local sources of its COPY and ADD instructions. Only files the sync rules
+// send reach the remote context, so the rest are skipped here too.
+// .dockerignore rules are not applied; hashing a few extra files only costs a
+// rebuild.
+func contextHash(root, dockerfile string, opts BuildOptions, syncOpts SyncOptions) (string, error) {
+	content, err := os.ReadFile(filepath.Join(root, dockerfile))
+	if err != nil {
+		return "", fmt.Errorf("failed to read Dockerfile: %w", err)
+	}
+
+	h := sha256.New()
+	io.WriteString(h, opts.hashInput())
+	fmt.Fprintf(h, "dockerfile %s\n", filepath.ToSlash(dockerfile))
+	h.Write(content)
+	if ignore, err := os.ReadFile(filepath.Join(root, ".dockerignore")); err == nil {
+		fmt.Fprintf(h, "\ndockerignore\n")
+		h.Write(ignore)
+	}
+
+	sources := copySources(string(content))
+	var synced []string
+	if len(sources) > 0 {
+		if synced, err = syncedFiles(root, syncOpts); err != nil {
+			return "", err
+		}
+	}
+	files := make(map[string]bool)
+	for _, src := range sources {
+		matches, err := filepath.Glob(filepath.Join(root, filepath.FromSlash(strings.TrimPrefix(src, "/"))))
+		if err != nil {
+			return "", fmt.Errorf("invalid COPY source %q: %w", src, err)
+		}
+		for _, m := range matches {
+			rel, err := filepath.Rel(root, m)
+			if err != nil {
+				return "", err
+			}
+			rel = filepath.ToSlash(rel)
+			for _, f := range synced {
+				if rel == "." || f == rel || strings.HasPrefix(f, rel+"/") {
+					files[f] = true
+				}
+			}
+		}
+	}
+
+	paths := make([]string, 0, len(files))
+	for p := range files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		if err := hashFile(h, root, p); err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// copySources returns the local source paths of COPY and ADD instructions.
+// Copies from other stages or images, heredocs and URLs are covered by the
+// Dockerfile's own hash.
+func copySources(dockerfile string) []string {
+	var sources []string
+	for _, line := range instructions(dockerfile) {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		if op := strings.ToUpper(fields[0]); op != "COPY" && op != "ADD" {
+			continue
+		}
+
+		args := fields[1:]
+		fromStage := false
+		for len(args) > 0 && strings.HasPrefix(args[0], "--") {
+			if strings.HasPrefix(args[0], "--from=") {
+				fromStage = true
+			}
+			args = args[1:]
+		}
+		if fromStage || len(args) == 0 {
+			continue
+		}
+
+		// Exec form: COPY ["src", "dest"]
+		if strings.HasPrefix(args[0], "[") {
+			var list []string
+			rest := strings.TrimSpace(line[strings.Index(line, "["):])
+			if err := json.Unmarshal([]byte(rest), &list); err != nil {
+				continue
+			}
+			args = list
+		}
+		if len(args) < 2 {
+			continue
+		}
+
+		for _, src := range args[:len(args)-1] {
+			if strings.HasPrefix(src, "<<") || strings.Contains(src, "://") || strings.HasPrefix(src, "git@") {
+				continue
+			}
+			sources = append(sources, src)
+		}
+	}
+	return sources
+}
+
+// instructions splits a Dockerfile into instructions, joining continuation
+// lines and dropping comments.
+func instructions(dockerfile string) []string {
+	var out []string
+	var current strings.Builder
+	scanner := bufio.NewScanner(strings.NewReader(dockerfile))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasSuffix(line, `\`) {
+			current.WriteString(strings.TrimSuffix(line, `\`) + " ")
+			continue
+		}
+		current.WriteString(line)
+		if s := strings.TrimSpace(current.String()); s != "" {
+			out = append(out, s)
+		}
+		current.Reset()
+	}
+	if s := strings.TrimSpace(current.String()); s != "" {
+		out = append(out, s)
+	}
+	return out
+}
+
+// syncedFiles lists the files a sync of root sends, as slash paths relative
+// to root. rsync applies the sync filters itself in a dry run into an empty
+// directory, so the list matches the sync exactly.
+func syncedFiles(root string, opts SyncOptions) ([]string, error) {
+	empty, err := os.MkdirTemp("", "osiris-context-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(empty)
+
+	filters, err := syncFilters(root, opts)
+	if err != nil {
+		return nil, err
+	}
+	args := []string{"-a", "--dry-run", "--out-format=%n"}
+	for _, rule := range filters {
+		args = append(args, "--filter="+rule)
+	}
+	args = append(args, strings.TrimSuffix(root, "/")+"/", empty+"/")
+
+	var stderr bytes.Buffer
+	cmd := exec.Command("rsync", args...)
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the build context: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	var files []string
+	for _, line := range strings.Split(string(output), "\n") {
+		if line == "" || strings.HasSuffix(line, "/") {
+			continue
+		}
+		files = append(files, line)
+	}
+	return files, nil
+}
+
+// hashFile writes a file's path, mode and content (or link target) to h.
+func hashFile(h io.Writer, root, rel string) error {
+	p := filepath.Join(root, filepath.FromSlash(rel))
+	info, err := os.Lstat(p)
+	if err != nil {
+		return fmt.Errorf("failed to hash %s: %w", p, err)
+	}
+	fmt.Fprintf(h, "\x00%s\x00%o\x00", rel, info.Mode())
+
+	if info.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(p)
+		if err != nil {
+			return fmt.Errorf("failed to hash %s: %w", p, err)
+		}
+		io.WriteString(h, target)
+		return nil
+	}
+
+	f, err := os.Open(p)
+	if err != nil {
+		return fmt.Errorf("failed to hash %s: %w", p, err)
+	}
+	defer f.Close()
+	if _, err := io.Copy(h, f); err != nil {
+		return fmt.Errorf("failed to hash %s: %w", p, err)
+	}
+	return nil
+}
@@ -0,0 +1,477 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// containersMarker separates job records from the live container list in ListJobs output.
+const containersMarker = "--osiris-containers--"
+
+var (
+	jobsSet     string
+	jobsGroupBy string
+)
+
+// Job is the metadata recorded on the remote for every run. Its ID doubles
+// as the container name so `logs` and `kill` accept either.
+type Job struct {
+	ID      string            `json:"id"`
+	Set     string            `json:"set,omitempty"`
+	Command string            `json:"command"`
+	Params  map[string]string `json:"params,omitempty"`
+	Image   string            `json:"image"`
+	ImageID string            `json:"image_id,omitempty"`
+	Created time.Time         `json:"created"`
+
+	// Env holds the container environment with secret values masked
+	Env    map[string]string `json:"env,omitempty"`
+	Docker DockerOptions     `json:"docker"`
+	Build  BuildOptions      `json:"build"`
+
+	// Git is the code version that was synced for the job
+	Git *GitInfo `json:"git,omitempty"`
+
+	// Workspace is the job's snapshot of the synced tree, mounted as /app;
+	// SharedCorpus directories are mounted from a location shared by all jobs
+	Workspace    string   `json:"workspace,omitempty"`
+	SharedCorpus []string `json:"shared_corpus,omitempty"`
+
+	// Seed is the seed corpus uploaded right before the job started
+	Seed *SeedCorpus `json:"seed,omitempty"`
+
+	// Fuzzer is set when osiris-lite knows which fuzzer the command runs
+	Fuzzer *FuzzerSettings `json:"fuzzer,omitempty"`
+
+	// Host the job runs on and, when placed automatically, why it was chosen
+	Host      string `json:"host,omitempty"`
+	Placement string `json:"placement,omitempty"`
+}
+
+// FuzzerSettings are the fuzzer settings a job runs with, from its adapter,
+// the fuzzer's config file and run's overrides. Corpus and Results are
+// relative to the project.
+type FuzzerSettings struct {
+	Name       string `json:"name"`
+	Config     string `json:"config,omitempty"`
+	Corpus     string `json:"corpus,omitempty"`
+	Results    string `json:"results,omitempty"`
+	StopSignal string `json:"stop_signal,omitempty"`
+	Workers    int    `json:"workers,omitempty"`
+	Timeout    int    `json:"timeout,omitempty"`
+
+	// Foundry invariant overrides
+	Runs         int   `json:"runs,omitempty"`
+	Depth        int   `json:"depth,omitempty"`
+	FailOnRevert *bool `json:"fail_on_revert,omitempty"`
+}
+
+// JobState is a Job together with its runtime state read back from the remote.
+type JobState struct {
+	Job
+	Status   string
+	ExitCode string
+	Started  string
+	Finished string
+	Pulled   string
+}
+
+func newJob(container, image, command string) Job {
+	return Job{
+		ID:      container + "-" + randomSuffix(),
+		Command: command,
+		Image:   image,
+		Created: time.Now().UTC(),
+
+		Host:      currentHost().Name,
+		Placement: placementReason,
+		Seed:      seedRecord,
+		Fuzzer:    fuzzerSettings,
+	}
+}
+
+func jobDir(remotePath, id string) string {
+	return path.Join(remotePath, stateDir, "jobs", id)
+}
+
+func newJobsCommand() *cobra.Command {
+	jobsCmd := &cobra.Command{
+		Use:   "jobs",
+		Short: "List jobs and their results",
+		RunE:  jobsCommand,
+	}
+	jobsCmd.Flags().StringVar(&jobsSet, "set", "", "Only show jobs of this job set, with one column per parameter")
+	jobsCmd.Flags().StringVar(&jobsGroupBy, "group-by", "", "Aggregate job outcomes by a matrix parameter (requires --set)")
+	jobsCmd.AddCommand(newJobsPruneCommand())
+	return jobsCmd
+}
+
+func jobsCommand(cmd *cobra.Command, args []string) error {
+	if jobsGroupBy != "" && jobsSet == "" {
+		return fmt.Errorf("--group-by requires --set")
+	}
+
+	client, err := connect(currentHost())
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	jobs, err := client.ListJobs(remotePath)
+	if err != nil {
+		return err
+	}
+
+	if jobsSet != "" {
+		var filtered []JobState
+		for _, j := range jobs {
+			if j.Set == jobsSet {
+				filtered = append(filtered, j)
+			}
+		}
+		if len(filtered) == 0 {
+			return fmt.Errorf("no jobs found for set %s", jobsSet)
+		}
+		if jobsGroupBy != "" {
+			return printJobGroups(filtered, jobsGroupBy)
+		}
+		printJobSet(filtered)
+		return nil
+	}
+
+	if len(jobs) == 0 {
+		fmt.Println("No jobs")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSET\tCOMMIT\tSTATUS\tSTARTED\tCOMMAND")
+	for _, j := range jobs {
+		set := j.Set
+		if set == "" {
+			set = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", j.ID, set, j.Git.describe(), j.describeStatus(), j.Started, j.Command)
+	}
+	return w.Flush()
+}
+
+// printJobSet prints one row per job with a column for each matrix parameter,
+// ordered by parameter values so related jobs sit together.
+func printJobSet(jobs []JobState) {
+	keys := paramKeys(jobs)
+	sort.SliceStable(jobs, func(a, b int) bool {
+		for _, k := range keys {
+			if va, vb := jobs[a].Params[k], jobs[b].Params[k]; va != vb {
+				return lessParam(va, vb)
+			}
+		}
+		return jobs[a].ID < jobs[b].ID
+	})
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "%s\tID\tSTATUS\tSTARTED\tFINISHED\n", strings.Join(upper(keys), "\t"))
+	for _, j := range jobs {
+		var values []string
+		for _, k := range keys {
+			values = append(values, j.Params[k])
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", strings.Join(values, "\t"), j.ID, j.describeStatus(), j.Started, j.Finished)
+	}
+	w.Flush()
+}
+
+// printJobGroups aggregates job outcomes per value of one parameter.
+func printJobGroups(jobs []JobState, key string) error {
+	type counts struct{ queued, running, passed, failed int }
+	groups := make(map[string]*counts)
+	for _, j := range jobs {
+		value, ok := j.Params[key]
+		if !ok {
+			return fmt.Errorf("jobs in this set have no parameter %q", key)
+		}
+		c := groups[value]
+		if c == nil {
+			c = &counts{}
+			groups[value] = c
+		}
+		switch {
+		case j.Status == "queued":
+			c.queued++
+		case j.Status == "running":
+			c.running++
+		case j.Status == "exited" && j.ExitCode == "0":
+			c.passed++
+		default:
+			c.failed++
+		}
+	}
+
+	var values []string
+	for v := range groups {
+		values = append(values, v)
+	}
+	sort.Slice(values, func(a, b int) bool { return lessParam(values[a], values[b]) })
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "%s\tJOBS\tQUEUED\tRUNNING\tEXIT 0\tFAILED\n", strings.ToUpper(key))
+	for _, v := range values {
+		c := groups[v]
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%d\n", v, c.queued+c.running+c.passed+c.failed, c.queued, c.running, c.passed, c.failed)
+	}
+	return w.Flush()
+}
+
+// summarizeJobSets renders one line per job set with its job counts by
+// status. Distributed campaigns are summarized separately.
+func summarizeJobSets(jobs []JobState) []string {
+	var order []string
+	counts := make(map[string]map[string]int)
+	for _, j := range jobs {
+		if j.Set == "" || isCampaign(j.Set) {
+			continue
+		}
+		if counts[j.Set] == nil {
+			counts[j.Set] = make(map[string]int)
+			order = append(order, j.Set)
+		}
+		status := j.Status
+		if status == "exited" && j.ExitCode != "0" {
+			status = "failed"
+		}
+		counts[j.Set][status]++
+	}
+
+	var lines []string
+	for _, set := range order {
+		c := counts[set]
+		total := 0
+		for _, n := range c {
+			total += n
+		}
+		lines = append(lines, fmt.Sprintf("%s: %d jobs (%d running, %d queued, %d done, %d failed, %d cancelled)",
+			set, total, c["running"], c["queued"], c["exited"], c["failed"]+c["lost"], c["cancelled"]))
+	}
+	return lines
+}
+
+// recentJobs returns the running jobs and up to limit of the most recently
+// created finished ones, oldest first.
+func recentJobs(jobs []JobState, limit int) []JobState {
+	var recent []JobState
+	finished := 0
+	for i := len(jobs) - 1; i >= 0; i-- {
+		j := jobs[i]
+		switch {
+		case j.Status == "running":
+		case j.Status == "exited" || j.Status == "lost":
+			if finished == limit {
+				continue
+			}
+			finished++
+		default:
+			continue
+		}
+		recent = append([]JobState{j}, recent...)
+	}
+	return recent
+}
+
+func (j JobState) describeStatus() string {
+	if j.Status == "exited" {
+		return "exited (" + j.ExitCode + ")"
+	}
+	return j.Status
+}
+
+func paramKeys(jobs []JobState) []string {
+	seen := make(map[string]bool)
+	var keys []string
+	for _, j := range jobs {
+		for k := range j.Params {
+			if !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func upper(values []string) []string {
+	out := make([]string, len(values))
+	for i, v := range values {
+		out[i] = strings.ToUpper(v)
+	}
+	return out
+}
+
+// jobScript renders the script that runs a job's container. It records the
+// job's status, timestamps, exit code and combined output in the job directory,
+// and tees the output so foreground runs still stream live.
+func jobScript(remotePath string, job Job) string {
+	dir := jobDir(remotePath, job.ID)
+
+	opts := fmt.Sprintf("--label osiris.job=%s", shellQuote(job.ID))
+	if job.Set != "" {
+		opts += fmt.Sprintf(" --label osiris.set=%s", shellQuote(job.Set))
+	}
+
+	// Only names go on the command line; docker reads values from the
+	// environment the script was started with
+	var envArgs []string
+	for name := range job.Env {
+		envArgs = append(envArgs, "-e "+name)
+	}
+	sort.Strings(envArgs)
+	if len(envArgs) > 0 {
+		opts += " " + strings.Join(envArgs, " ")
+	}
+	if extra := job.Docker.args(); extra != "" {
+		opts += " " + extra
+	}
+
+	// docker stop, and so kill, sends the fuzzer its graceful stop signal
+	if job.Fuzzer != nil && job.Fuzzer.StopSignal != "" {
+		opts += " --stop-signal " + shellQuote(job.Fuzzer.StopSignal)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "#!/bin/sh\n# Generated by osiris-lite for job %s. Do not edit.\n", job.ID)
+	fmt.Fprintf(&b, "dir=%s\n", shellQuote(dir))
+	fmt.Fprintf(&b, "cd %s || exit 1\n", shellQuote(job.Workspace))
+	b.WriteString(`[ -e "$dir/cancelled" ] && exit 0
+echo running > "$dir/status"
+date -u +%Y-%m-%dT%H:%M:%SZ > "$dir/started"
+`)
+	fmt.Fprintf(&b, "{ docker run --rm %s %s -w /app --name %s %s bash -c %s 2>&1; echo $? > \"$dir/exit_code\"; } | tee \"$dir/output.log\"\n",
+		opts, job.mountArgs(remotePath), shellQuote(job.ID), shellQuote(job.Image), shellQuote(job.Command))
+	b.WriteString(`code=$(cat "$dir/exit_code" 2>/dev/null || echo 1)
+date -u +%Y-%m-%dT%H:%M:%SZ > "$dir/finished"
+echo "exited $code" > "$dir/status"
+exit "$code"
+`)
+	return b.String()
+}
+
+// CreateJob snapshots the synced tree into the job's workspace, writes its
+// metadata and run script to the remote and marks it queued.
+func (s *SSHClient) CreateJob(remotePath string, job Job) error {
+	dir := jobDir(remotePath, job.ID)
+	if _, err := s.RunCommand(fmt.Sprintf("mkdir -p %s && echo queued > %s", shellQuote(dir), shellQuote(path.Join(dir, "status")))); err != nil {
+		return fmt.Errorf("failed to create job directory: %w", err)
+	}
+
+	job.Workspace = workspaceDir(remotePath, job.ID)
+	if output, err := s.RunCommandWithInput("sh", strings.NewReader(snapshotScript(remotePath, job))); err != nil {
+		return fmt.Errorf("failed to create job workspace: %w: %s", err, strings.TrimSpace(output))
+	}
+
+	// Metadata never carries secrets; the script needs the real command
+	recorded := job
+	recorded.Command = maskSecrets(job.Command)
+	meta, err := json.Marshal(recorded)
+	if err != nil {
+		return err
+	}
+	if _, err := s.RunCommandWithInput(fmt.Sprintf("cat > %s", shellQuote(path.Join(dir, "job.json"))), strings.NewReader(string(meta)+"\n")); err != nil {
+		return fmt.Errorf("failed to write job metadata: %w", err)
+	}
+
+	script := path.Join(dir, "run.sh")
+	if _, err := s.RunCommandWithInput(fmt.Sprintf("cat > %s", shellQuote(script)), strings.NewReader(jobScript(remotePath, job))); err != nil {
+		return fmt.Errorf("failed to write job script: %w", err)
+	}
+
+	// Uncommitted changes are kept with the job so the run can be reproduced
+	if job.Git != nil && job.Git.diff != "" {
+		if _, err := s.RunCommandWithInput(fmt.Sprintf("cat > %s", shellQuote(path.Join(dir, "dirty.diff"))), strings.NewReader(job.Git.diff)); err != nil {
+			return fmt.Errorf("failed to write job diff: %w", err)
+		}
+	}
+	return nil
+}
+
+// ListJobs reads every job recorded under remote-path, oldest first. Jobs whose
+// script died without recording an exit code (e.g. a dropped foreground
+// session) are reported as "lost".
+func (s *SSHClient) ListJobs(remotePath string) ([]JobState, error) {
+	root := path.Join(remotePath, stateDir, "jobs")
+	listCmd := fmt.Sprintf(`for d in %s/*/; do [ -f "$d/job.json" ] || continue; cat "$d/job.json"; printf '%%s\t%%s\t%%s\t%%s\n' "$(cat "$d/status" 2>/dev/null)" "$(cat "$d/started" 2>/dev/null)" "$(cat "$d/finished" 2>/dev/null)" "$(cat "$d/pulled" 2>/dev/null)"; done; echo %s; docker ps --filter label=osiris.job --format '{{.Label "osiris.job"}}' 2>/dev/null || true`, shellQuote(root), containersMarker)
+	output, err := s.RunCommand(listCmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list jobs: %w", err)
+	}
+
+	records, containers, _ := strings.Cut(output, containersMarker+"\n")
+	alive := make(map[string]bool)
+	for _, id := range strings.Fields(containers) {
+		alive[id] = true
+	}
+
+	var jobs []JobState
+	lines := strings.Split(strings.TrimSpace(records), "\n")
+	for i := 0; i+1 < len(lines); i += 2 {
+		var state JobState
+		if err := json.Unmarshal([]byte(lines[i]), &state.Job); err != nil {
+			return nil, fmt.Errorf("failed to parse job metadata: %w", err)
+		}
+		fields := strings.Split(lines[i+1], "\t")
+		for len(fields) < 4 {
+			fields = append(fields, "")
+		}
+		state.Status, state.ExitCode, _ = strings.Cut(fields[0], " ")
+		state.Started, state.Finished, state.Pulled = fields[1], fields[2], fields[3]
+		if state.Status == "running" && !alive[state.ID] {
+			state.Status = "lost"
+		}
+		jobs = append(jobs, state)
+	}
+
+	sort.SliceStable(jobs, func(a, b int) bool { return jobs[a].Created.Before(jobs[b].Created) })
+	return jobs, nil
+}
+
+// StartJobSet queues already created jobs and starts a detached runner that
+// keeps at most parallel of them running at once.
+func (s *SSHClient) StartJobSet(remotePath, setID string, jobs []Job, parallel int, env jobEnv) error {
+	dir := path.Join(remotePath, stateDir, "sets", setID)
+
+	var queue strings.Builder
+	for _, job := range jobs {
+		queue.WriteString(path.Join(jobDir(remotePath, job.ID), "run.sh") + "\n")
+	}
+	if _, err := s.RunCommandWithInput(fmt.Sprintf("mkdir -p %s && cat > %s", shellQuote(dir), shellQuote(path.Join(dir, "queue"))), strings.NewReader(queue.String())); err != nil {
+		return fmt.Errorf("failed to write job queue: %w", err)
+	}
+
+	// The runner inherits the environment from stdin and passes it to every job
+	runner := fmt.Sprintf(`cd %s && nohup xargs -P %d -I {} sh {} < queue > runner.log 2>&1 & echo $! > %s`,
+		shellQuote(dir), parallel, shellQuote(path.Join(dir, "pid")))
+	if _, err := s.RunCommandWithInput(withEnv(runner), strings.NewReader(env.exports())); err != nil {
+		return fmt.Errorf("failed to start job runner: %w", err)
+	}
+	return nil
+}
+
+// RemoteCapacity returns the number of CPU cores on the remote.
+func (s *SSHClient) RemoteCapacity() (int, error) {
+	output, err := s.RunCommand("nproc")
+	if err != nil {
+		return 0, fmt.Errorf("failed to read remote core count: %w", err)
+	}
+	var n int
+	if _, err := fmt.Sscanf(strings.TrimSpace(output), "%d", &n); err != nil || n < 1 {
+		return 0, fmt.Errorf("unexpected nproc output %q", output)
+	}
+	return n, nil
+}
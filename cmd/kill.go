@@ -12,28 +12,23 @@ func killCommand(cmd *cobra.Command, args []string) error {
 		target = args[0]
 	}
 
-	var client *SSHClient
-	var err error
-
-	if password != "" {
-		client, err = NewSSHClientWithPassword(remote, password)
-	} else {
-		client, err = NewSSHClient(remote)
-	}
+	// Accept host:job and host:all addresses
+	host, target := splitAddress(target)
 
+	client, err := connect(host)
 	if err != nil {
-		return fmt.Errorf("failed to connect to remote: %w", err)
+		return err
 	}
 	defer client.Close()
 
 	if target == "all" {
 		fmt.Println("Killing all jobs...")
-		return client.KillAll(image)
+		return client.KillAll(host.RemotePath)
 	}
 
 	if target != "" {
 		fmt.Printf("Killing container: %s\n", target)
-		return client.KillContainer(target)
+		return client.KillContainer(host.RemotePath, target)
 	}
 
 	fmt.Println("\nUse 'kill all' or 'kill <container_id>'")
@@ -1,30 +1,23 @@
 package cmd
 
 import (
-	"fmt"
-
 	"github.com/spf13/cobra"
 )
 
 func logsCommand(cmd *cobra.Command, args []string) error {
-	var client *SSHClient
-	var err error
-
-	if password != "" {
-		client, err = NewSSHClientWithPassword(remote, password)
-	} else {
-		client, err = NewSSHClient(remote)
+	var containerID string
+	if len(args) > 0 {
+		containerID = args[0]
 	}
 
+	// Accept host:job addresses; "host:" picks the first running container there
+	host, containerID := splitAddress(containerID)
+
+	client, err := connect(host)
 	if err != nil {
-		return fmt.Errorf("failed to connect to remote: %w", err)
+		return err
 	}
 	defer client.Close()
 
-	var containerID string
-	if len(args) > 0 {
-		containerID = args[0]
-	}
-
-	return client.ConnectToLogs(image, containerID)
+	return client.ConnectToLogs(containerID)
 }
@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var placeholderPattern = regexp.MustCompile(`{{\s*([A-Za-z_][A-Za-z0-9_]*)\s*}}`)
+
+// maxMatrixJobs caps the number of jobs a --matrix sweep expands to.
+const maxMatrixJobs = 4096
+
+// matrixAxis is one --matrix parameter and the values it sweeps.
+type matrixAxis struct {
+	Name   string
+	Values []string
+}
+
+// parseMatrix parses --matrix specs of the form name=1..8 or name=a,b,c. It
+// rejects sweeps of more than maxMatrixJobs combinations.
+func parseMatrix(specs []string) ([]matrixAxis, error) {
+	var axes []matrixAxis
+	seen := make(map[string]bool)
+	combos := 1
+	for _, spec := range specs {
+		name, values, ok := strings.Cut(spec, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" || values == "" {
+			return nil, fmt.Errorf("invalid --matrix %q: expected name=values", spec)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("matrix parameter %q given more than once", name)
+		}
+		seen[name] = true
+
+		axis := matrixAxis{Name: name}
+		if lo, hi, isRange := strings.Cut(values, ".."); isRange {
+			start, err1 := strconv.Atoi(lo)
+			end, err2 := strconv.Atoi(hi)
+			if err1 != nil || err2 != nil || end < start {
+				return nil, fmt.Errorf("invalid range %q for matrix parameter %q", values, name)
+			}
+			if end-start >= maxMatrixJobs || end-start < 0 {
+				return nil, fmt.Errorf("matrix exceeds %d jobs: range %q for %q has more values", maxMatrixJobs, values, name)
+			}
+			for i := start; i <= end; i++ {
+				axis.Values = append(axis.Values, strconv.Itoa(i))
+			}
+		} else {
+			for _, v := range strings.Split(values, ",") {
+				if v = strings.TrimSpace(v); v != "" {
+					axis.Values = append(axis.Values, v)
+				}
+			}
+		}
+		if len(axis.Values) == 0 {
+			return nil, fmt.Errorf("matrix parameter %q has no values", name)
+		}
+		if combos *= len(axis.Values); combos > maxMatrixJobs {
+			return nil, fmt.Errorf("matrix exceeds %d jobs", maxMatrixJobs)
+		}
+		axes = append(axes, axis)
+	}
+	return axes, nil
+}
+
+// expandMatrix returns the cartesian product of all axes, in the order given.
+func expandMatrix(axes []matrixAxis) []map[string]string {
+	combos := []map[string]string{{}}
+	for _, axis := range axes {
+		var next []map[string]string
+		for _, combo := range combos {
+			for _, v := range axis.Values {
+				params := make(map[string]string, len(combo)+1)
+				for k, cv := range combo {
+					params[k] = cv
+				}
+				params[axis.Name] = v
+				next = append(next, params)
+			}
+		}
+		combos = next
+	}
+	return combos
+}
+
+// checkTemplate ensures every {{placeholder}} in the command has a matrix axis.
+func checkTemplate(command string, axes []matrixAxis) error {
+	defined := make(map[string]bool)
+	for _, axis := range axes {
+		defined[axis.Name] = true
+	}
+	used := make(map[string]bool)
+	for _, m := range placeholderPattern.FindAllStringSubmatch(command, -1) {
+		if !defined[m[1]] {
+			return fmt.Errorf("command uses {{%s}} but no --matrix %s=... was given", m[1], m[1])
+		}
+		used[m[1]] = true
+	}
+	for _, axis := range axes {
+		if !used[axis.Name] {
+			fmt.Printf("Warning: matrix parameter %q is not used in the command\n", axis.Name)
+		}
+	}
+	return nil
+}
+
+func renderTemplate(command string, params map[string]string) string {
+	return placeholderPattern.ReplaceAllStringFunc(command, func(m string) string {
+		return params[placeholderPattern.FindStringSubmatch(m)[1]]
+	})
+}
+
+// lessParam orders parameter values numerically when both are numbers.
+func lessParam(a, b string) bool {
+	na, errA := strconv.ParseFloat(a, 64)
+	nb, errB := strconv.ParseFloat(b, 64)
+	if errA == nil && errB == nil {
+		return na < nb
+	}
+	return a < b
+}
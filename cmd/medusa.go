@@ -0,0 +1,203 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// medusaConfigFile is the config medusa fuzz reads unless given --config.
+const medusaConfigFile = "medusa.json"
+
+// The log lines status needs: test outcomes and the final summary, and the
+// latest periodic metrics line.
+const (
+	medusaProgress = `\[(PASSED|FAILED)\]|Test summary:`
+	medusaLatest   = `fuzz: elapsed`
+)
+
+var (
+	ansiEscape    = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+	medusaMetrics = regexp.MustCompile(`fuzz: elapsed: (\S+), calls: (\d+).*?(?:branches hit|coverage): (\d+), corpus: (\d+)`)
+	medusaTest    = regexp.MustCompile(`\[(PASSED|FAILED)\] (\w+) Test: (\S+)`)
+	medusaCall    = regexp.MustCompile(`^\s*\d+\) (.+)`)
+	medusaSummary = regexp.MustCompile(`Test summary: (\d+) test\(s\) passed, (\d+) test\(s\) failed`)
+	medusaCommand = regexp.MustCompile(`\bmedusa\s+fuzz\b`)
+
+	// configFlagPattern finds the config file a fuzzer command passes
+	configFlagPattern = regexp.MustCompile(`--config[= ](\S+)`)
+)
+
+// medusaConfig is the part of medusa.json osiris-lite reads.
+type medusaConfig struct {
+	Fuzzing struct {
+		Workers         int    `json:"workers"`
+		Timeout         int    `json:"timeout"`
+		CorpusDirectory string `json:"corpusDirectory"`
+	} `json:"fuzzing"`
+}
+
+type medusaAdapter struct{}
+
+func (medusaAdapter) Name() string { return "medusa" }
+
+func (medusaAdapter) Spec() AdapterSpec {
+	return AdapterSpec{
+		Command:    "medusa fuzz --config {{config}} {{args}}",
+		Config:     medusaConfigFile,
+		StopSignal: "SIGINT",
+		Progress:   medusaProgress,
+		Latest:     medusaLatest,
+	}
+}
+
+func (medusaAdapter) Matches(command string) bool {
+	return medusaCommand.MatchString(command)
+}
+
+func (medusaAdapter) Parse(r io.Reader) (*Results, error) {
+	return parseMedusa(r)
+}
+
+// Prepare reads the corpus directory, worker count and timeout from the
+// medusa.json the command uses in the synced tree at root, and applies the
+// run --timeout, --workers and --corpus-dir overrides as medusa fuzz flags.
+func (a medusaAdapter) Prepare(root, command string, env jobEnv) (*FuzzerSettings, string, error) {
+	if err := checkOverrides(a.Name(), "timeout", "workers", "corpus-dir"); err != nil {
+		return nil, "", err
+	}
+	settings := newSettings(a)
+	if m := configFlagPattern.FindStringSubmatch(command); m != nil {
+		settings.Config = strings.Trim(m[1], `'"`)
+	}
+
+	data, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(settings.Config)))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, "", fmt.Errorf("failed to read %s: %w", settings.Config, err)
+	}
+	if err == nil {
+		var cfg medusaConfig
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			return nil, "", fmt.Errorf("failed to parse %s: %w", settings.Config, err)
+		}
+		settings.Workers = cfg.Fuzzing.Workers
+		settings.Timeout = cfg.Fuzzing.Timeout
+		settings.Corpus = cfg.Fuzzing.CorpusDirectory
+	}
+
+	var flags []string
+	if fuzzTimeout > 0 {
+		settings.Timeout = int(fuzzTimeout.Seconds())
+		flags = append(flags, "--timeout", strconv.Itoa(settings.Timeout))
+	}
+	if fuzzWorkers > 0 {
+		settings.Workers = fuzzWorkers
+		flags = append(flags, "--workers", strconv.Itoa(fuzzWorkers))
+	}
+	if fuzzCorpusDir != "" {
+		rel, ok := projectRelative(fuzzCorpusDir)
+		if !ok {
+			return nil, "", fmt.Errorf("invalid --corpus-dir %q: expected a directory inside the project", fuzzCorpusDir)
+		}
+		settings.Corpus = rel
+		flags = append(flags, "--corpus-dir", shellQuote(rel))
+	}
+	if len(flags) == 0 {
+		return settings, command, nil
+	}
+
+	// Flags go right after "medusa fuzz"
+	loc := medusaCommand.FindStringIndex(command)
+	if loc == nil {
+		return nil, "", fmt.Errorf("--timeout, --workers and --corpus-dir need the command to call medusa fuzz directly")
+	}
+	return settings, command[:loc[1]] + " " + strings.Join(flags, " ") + command[loc[1]:], nil
+}
+
+// parseMedusa reads Medusa's output into results: the outcome of every
+// property and assertion test with the call sequence that fails it, and the
+// branch coverage and corpus size from the periodic metrics lines. Failures
+// are reported as they are found; passing tests and the test count only at
+// the end of the campaign.
+func parseMedusa(r io.Reader) (*Results, error) {
+	results := &Results{Fuzzer: "medusa", CoverageUnit: "branches"}
+	index := make(map[string]int)
+	var current *TestResult
+	inSequence := false
+	summarized := false
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		line := ansiEscape.ReplaceAllString(scanner.Text(), "")
+
+		if m := medusaTest.FindStringSubmatch(line); m != nil {
+			current, inSequence = nil, false
+			if m[2] == "Optimization" {
+				continue
+			}
+			status := "passed"
+			if m[1] == "FAILED" {
+				status = "failed"
+			}
+
+			// The final report repeats failures found during the campaign
+			i, seen := index[m[3]]
+			if !seen {
+				i = len(results.Tests)
+				index[m[3]] = i
+				results.Tests = append(results.Tests, TestResult{Name: m[3]})
+			}
+			results.Tests[i].Status = status
+			current = &results.Tests[i]
+			continue
+		}
+
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case current != nil && trimmed == "[Call Sequence]":
+			inSequence = true
+			current.Sequence = nil
+			continue
+		case inSequence:
+			if m := medusaCall.FindStringSubmatch(line); m != nil {
+				current.Sequence = append(current.Sequence, strings.TrimSpace(m[1]))
+				continue
+			}
+			inSequence = false
+		}
+
+		if m := medusaMetrics.FindStringSubmatch(line); m != nil {
+			elapsed, _ := time.ParseDuration(m[1])
+			results.Calls, _ = strconv.Atoi(m[2])
+			cov, _ := strconv.Atoi(m[3])
+			corpus, _ := strconv.Atoi(m[4])
+			results.observe(CoveragePoint{Elapsed: elapsed, Coverage: cov, Corpus: corpus})
+		} else if m := medusaSummary.FindStringSubmatch(line); m != nil {
+			passed, _ := strconv.Atoi(m[1])
+			failed, _ := strconv.Atoi(m[2])
+			results.Total, results.Broken = passed+failed, failed
+			summarized = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read Medusa output: %w", err)
+	}
+
+	if !summarized {
+		for _, t := range results.Tests {
+			if t.Status == "failed" {
+				results.Broken++
+			}
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,52 @@
+package cmd
+
+import "testing"
+
+func TestParseMedusa(t *testing.T) {
+	tests := []struct {
+		name    string
+		fixture string
+		want    Results
+		points  int
+	}{
+		{
+			name:    "finished campaign",
+			fixture: "medusa/fuzz.log",
+			want: Results{
+				Fuzzer: "medusa", Broken: 1, Total: 3,
+				Tests: []TestResult{
+					{Name: "CryticTester.handler_withdraw(uint256)", Status: "failed", Sequence: []string{
+						"CryticTester.handler_withdraw(uint256)(6) (block=2, time=4, gas=12500000, gasprice=1, value=0, sender=0x20000)",
+					}},
+					{Name: "CryticTester.handler_deposit(uint256)", Status: "passed"},
+					{Name: "CryticTester.property_solvency()", Status: "passed"},
+				},
+				Coverage: 512, CorpusSize: 31, Calls: 64012,
+			},
+			points: 3,
+		},
+		{
+			name:    "progress lines while running",
+			fixture: "medusa/running.log",
+			want: Results{
+				Fuzzer: "medusa", Broken: 1,
+				Tests:    []TestResult{{Name: "CryticTester.handler_withdraw(uint256)", Status: "failed"}},
+				Coverage: 512, CorpusSize: 31, Calls: 43210,
+			},
+			points: 1,
+		},
+		{
+			name:    "colored output",
+			fixture: "medusa/ansi.log",
+			want: Results{
+				Fuzzer: "medusa", Broken: 1,
+				Tests: []TestResult{{Name: "CryticTester.property_solvency()", Status: "failed"}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkResults(t, parseFixture(t, parseMedusa, tt.fixture), &tt.want, tt.points)
+		})
+	}
+}
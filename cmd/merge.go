@@ -0,0 +1,244 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	mergeOut  string
+	mergePush bool
+)
+
+// MergeRecord is written next to a merged corpus.
+type MergeRecord struct {
+	Merged    time.Time     `json:"merged"`
+	Sources   []MergeSource `json:"sources"`
+	Sequences int           `json:"sequences"`
+}
+
+// MergeSource is one job's contribution to a merged corpus.
+type MergeSource struct {
+	Job       string `json:"job"`
+	Host      string `json:"host"`
+	Sequences int    `json:"sequences"`
+	New       int    `json:"new"`
+
+	dir string
+}
+
+func newCorpusCommand() *cobra.Command {
+	corpusCmd := &cobra.Command{
+		Use:   "corpus",
+		Short: "Work with fuzzing corpora across jobs and hosts",
+	}
+
+	mergeCmd := &cobra.Command{
+		Use:   "merge <[host:]job>...",
+		Short: "Pull the corpora of several jobs and merge them without duplicate call sequences",
+		Long: `Pull the corpora of several jobs, possibly on different hosts, and merge them.
+
+Call sequences are deduplicated by a hash of their JSON content, per corpus
+subdirectory such as coverage/ or reproducers/. Other files, like coverage
+reports, are left out. The merged corpus is written to <out>/corpus with a
+merge.json describing what each job contributed.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: corpusMergeCommand,
+	}
+	mergeCmd.Flags().StringVarP(&mergeOut, "out", "o", "", "Output directory (default: <results-path>/merged)")
+	mergeCmd.Flags().BoolVar(&mergePush, "push", false, "Upload the merged corpus to the current host as the seed for the next run")
+	mergeCmd.Flags().StringVar(&corpusDest, "dest", "", "Corpus directory for --push, relative to the project (default: the fuzzer config's corpus directory, else the results directory)")
+	mergeCmd.Flags().StringVar(&fuzzerName, "fuzzer", "", "Fuzzer adapter whose config sets the corpus directory for --push, when the project has several")
+	mergeCmd.Flags().BoolVar(&pullStream, "stream", false, "Pull each job as one tar | zstd stream over SSH instead of rsync")
+
+	corpusCmd.AddCommand(mergeCmd, newCorpusExchangeCommand())
+	return corpusCmd
+}
+
+func corpusMergeCommand(cmd *cobra.Command, args []string) error {
+	rel, err := remoteResultsDir()
+	if err != nil {
+		return err
+	}
+	if resultsPath == "" {
+		return fmt.Errorf("no local results path: set results-path")
+	}
+	resultsPath = expandPath(resultsPath)
+	if mergeOut == "" {
+		mergeOut = filepath.Join(resultsPath, "merged")
+	}
+	mergeOut = expandPath(mergeOut)
+
+	// Fail before pulling anything
+	var dest string
+	var wsOpts WorkspaceOptions
+	if mergePush {
+		if dest, err = seedDest(".", nil); err != nil {
+			return err
+		}
+		if wsOpts, err = loadWorkspaceOptions(); err != nil {
+			return err
+		}
+	}
+
+	// Each job is pulled into its own directory, as `pull <job>` does
+	var sources []*MergeSource
+	for _, arg := range args {
+		h, id := splitAddress(arg)
+		name := h.Name
+		if name == "" {
+			name = h.Remote
+		}
+
+		client, err := connect(h)
+		if err != nil {
+			return err
+		}
+		localPath := filepath.Join(resultsPath, "jobs", id)
+		fmt.Printf("Pulling job %s from %s...\n", id, name)
+		pulled, err := client.PullJob(h.RemotePath, id, rel, localPath, nil, nil)
+		client.Close()
+		if err != nil {
+			return err
+		}
+		corpus, ok := jobCorpus(pulled, rel)
+		if !ok {
+			fmt.Printf("⚠ Job %s has no corpus, skipping\n", id)
+			continue
+		}
+		sources = append(sources, &MergeSource{Job: id, Host: name, dir: filepath.Join(localPath, filepath.FromSlash(corpus))})
+	}
+	if len(sources) == 0 {
+		return fmt.Errorf("none of the jobs has a corpus to merge")
+	}
+
+	corpusDir := filepath.Join(mergeOut, "corpus")
+	total, err := mergeCorpora(sources, corpusDir)
+	if err != nil {
+		return err
+	}
+
+	record := MergeRecord{Merged: time.Now().UTC(), Sequences: total}
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "JOB\tHOST\tSEQUENCES\tNEW")
+	for _, s := range sources {
+		record.Sources = append(record.Sources, *s)
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\n", s.Job, s.Host, s.Sequences, s.New)
+	}
+	w.Flush()
+
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(mergeOut, "merge.json"), append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write merge record: %w", err)
+	}
+	fmt.Printf("✓ Merged corpus: %d unique call sequences in %s\n", total, corpusDir)
+
+	if !mergePush {
+		return nil
+	}
+	client, err := connect(currentHost())
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	_, err = client.PushCorpus(remotePath, corpusDir, dest, wsOpts)
+	return err
+}
+
+// jobCorpus picks the directory holding a pulled job's call sequences: the
+// corpus directory of its fuzzer adapter, else the results directory rel.
+func jobCorpus(pulled []pulledDir, rel string) (string, bool) {
+	for _, p := range pulled {
+		if p.Corpus {
+			return p.Rel, true
+		}
+	}
+	for _, p := range pulled {
+		if p.Rel == rel {
+			return rel, true
+		}
+	}
+	return "", false
+}
+
+// mergeCorpora copies the call sequences of every source into dst, named by
+// content hash so each sequence is kept once per subdirectory. Sequences
+// already in dst from an earlier merge count as duplicates. It returns the
+// number of sequences in dst.
+func mergeCorpora(sources []*MergeSource, dst string) (int, error) {
+	for _, src := range sources {
+		err := filepath.WalkDir(src.dir, func(p string, d os.DirEntry, err error) error {
+			if err != nil || !d.Type().IsRegular() || strings.HasSuffix(p, partSuffix) {
+				return err
+			}
+			data, err := os.ReadFile(p)
+			if err != nil {
+				return err
+			}
+			canonical, ok := callSequence(data)
+			if !ok {
+				return nil
+			}
+			src.Sequences++
+
+			rel, _ := filepath.Rel(src.dir, p)
+			category := filepath.Dir(rel)
+			ext := path.Ext(d.Name())
+			if ext == "" {
+				ext = ".txt"
+			}
+			sum := sha256.Sum256(canonical)
+			target := filepath.Join(dst, category, hex.EncodeToString(sum[:16])+ext)
+			if _, err := os.Stat(target); err == nil {
+				return nil
+			}
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			if err := os.WriteFile(target, data, 0644); err != nil {
+				return err
+			}
+			src.New++
+			return nil
+		})
+		if err != nil {
+			return 0, fmt.Errorf("failed to merge corpus of %s: %w", src.Job, err)
+		}
+	}
+
+	files, err := listFiles(dst, dst)
+	if err != nil {
+		return 0, err
+	}
+	return len(files), nil
+}
+
+// callSequence reports whether data is a call sequence, a non-empty JSON
+// array of transactions, and returns it compacted so formatting differences
+// do not defeat deduplication.
+func callSequence(data []byte) ([]byte, bool) {
+	var seq []json.RawMessage
+	if json.Unmarshal(data, &seq) != nil || len(seq) == 0 {
+		return nil, false
+	}
+	var compact bytes.Buffer
+	if json.Compact(&compact, data) != nil {
+		return nil, false
+	}
+	return compact.Bytes(), true
+}
@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// minDiskHeadroomKB is the free space below which a host is never chosen.
+const minDiskHeadroomKB = 5 * 1024 * 1024
+
+// placementTimeout bounds how long placement waits for a host's probe.
+const placementTimeout = 15 * time.Second
+
+// isHostSelector reports whether --host asks for automatic placement rather
+// than naming a host.
+func isHostSelector(value string) bool {
+	return value == "auto" || strings.HasPrefix(value, "tag=")
+}
+
+// placeJob picks the host with the most headroom among those matching the
+// selector, using live probes. It returns the host and a human readable reason.
+func placeJob(selector string) (Host, string, error) {
+	if len(hosts) == 0 {
+		return Host{}, "", fmt.Errorf("--host %s needs a hosts section in the config", selector)
+	}
+
+	var required []string
+	if tags, ok := strings.CutPrefix(selector, "tag="); ok {
+		required = strings.Split(tags, ",")
+	}
+
+	var candidates []Host
+	for _, h := range fleetHosts() {
+		if hasTags(h, required) {
+			candidates = append(candidates, h)
+		}
+	}
+	if len(candidates) == 0 {
+		return Host{}, "", fmt.Errorf("no host matches %s", selector)
+	}
+
+	fmt.Printf("Probing %d candidate hosts...\n", len(candidates))
+	results := forEachHost(candidates, placementTimeout, func(h Host, client *SSHClient) (HostStats, error) {
+		return client.Probe(h.RemotePath)
+	})
+
+	type scored struct {
+		host   Host
+		stats  HostStats
+		slots  int
+		score  float64
+		reason string
+	}
+	var ranked []scored
+	for _, r := range results {
+		if r.Err != nil {
+			fmt.Printf("  %s: skipped (%v)\n", r.Host.Name, r.Err)
+			continue
+		}
+		stats := r.Value
+		if stats.DiskAvailKB < minDiskHeadroomKB {
+			fmt.Printf("  %s: skipped (only %s disk free)\n", r.Host.Name, formatKB(stats.DiskAvailKB))
+			continue
+		}
+		slots := hostSlots(r.Host, stats)
+		score := placementScore(r.Host, stats)
+		ranked = append(ranked, scored{
+			host:  r.Host,
+			stats: stats,
+			slots: slots,
+			score: score,
+			reason: fmt.Sprintf("%d/%d job slots free, load %.2f on %d cores, %s memory and %s disk available",
+				max(slots, 0), hostSlots(r.Host, HostStats{Cores: stats.Cores}), stats.Load1, stats.Cores,
+				formatKB(stats.MemAvailKB), formatKB(stats.DiskAvailKB)),
+		})
+	}
+	if len(ranked) == 0 {
+		return Host{}, "", fmt.Errorf("no reachable host with enough disk matches %s", selector)
+	}
+
+	// Hosts with a free slot always beat full ones, then the best score wins
+	sort.SliceStable(ranked, func(a, b int) bool {
+		if (ranked[a].slots > 0) != (ranked[b].slots > 0) {
+			return ranked[a].slots > 0
+		}
+		return ranked[a].score > ranked[b].score
+	})
+
+	best := ranked[0]
+	if best.slots <= 0 {
+		fmt.Printf("Warning: every candidate host is at capacity\n")
+	}
+	reason := fmt.Sprintf("%s (best of %d candidates for --host %s)", best.reason, len(candidates), selector)
+	return best.host, reason, nil
+}
+
+func hasTags(h Host, required []string) bool {
+	for _, want := range required {
+		found := false
+		for _, tag := range h.Tags {
+			if tag == strings.TrimSpace(want) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// hostSlots is the number of additional osiris jobs the host can take.
+func hostSlots(h Host, stats HostStats) int {
+	limit := h.Capacity
+	if limit == 0 {
+		limit = stats.Cores
+	}
+	return limit - stats.RunningJobs
+}
+
+// placementScore weighs idle CPU, free memory, free job slots and disk
+// headroom into a single number between 0 and 1.
+func placementScore(h Host, stats HostStats) float64 {
+	if stats.Cores == 0 || stats.MemTotalKB == 0 {
+		return 0
+	}
+	idle := (float64(stats.Cores) - stats.Load1) / float64(stats.Cores)
+	mem := float64(stats.MemAvailKB) / float64(stats.MemTotalKB)
+	slots := float64(hostSlots(h, stats)) / float64(hostSlots(h, HostStats{Cores: stats.Cores}))
+	disk := min(float64(stats.DiskAvailKB)/float64(4*minDiskHeadroomKB), 1)
+	return 0.35*clamp(idle) + 0.25*clamp(mem) + 0.3*clamp(slots) + 0.1*disk
+}
+
+func clamp(v float64) float64 {
+	return max(0, min(v, 1))
+}
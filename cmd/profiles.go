@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// defaultProfile is the profile block every other profile inherits from. It
+// also applies on its own when no profile is selected.
+const defaultProfile = "default"
+
+// applyProfile layers the default profile and then the selected one over the
+// top-level config file values. Profiles sit in the file layer, so env vars
+// and flags still override them.
+func applyProfile(name string) error {
+	// Viper lowercases keys, so profile names are case-insensitive
+	name = strings.ToLower(name)
+	profiles := viper.GetStringMap("profiles")
+
+	layers := []string{defaultProfile}
+	if name != "" && name != defaultProfile {
+		if _, ok := profiles[name]; !ok {
+			return fmt.Errorf("unknown profile %q (available: %s)", name, strings.Join(profileNames(profiles), ", "))
+		}
+		layers = append(layers, name)
+	}
+
+	for _, p := range layers {
+		raw, ok := profiles[p]
+		if !ok || raw == nil {
+			continue
+		}
+		block, ok := raw.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("profile %q must be a map of settings", p)
+		}
+		if _, nested := block["profiles"]; nested {
+			return fmt.Errorf("profile %q must not define profiles", p)
+		}
+		if err := viper.MergeConfigMap(block); err != nil {
+			return fmt.Errorf("failed to apply profile %q: %w", p, err)
+		}
+	}
+	return nil
+}
+
+func profileNames(profiles map[string]interface{}) []string {
+	var names []string
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
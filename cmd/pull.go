@@ -1,33 +1,240 @@
 package cmd
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 )
 
+// manifestFile is written into every per-job pull directory.
+const manifestFile = "manifest.json"
+
+var (
+	pullIncludes []string
+	pullExcludes []string
+)
+
+// PullManifest describes what a job pull fetched and from where.
+type PullManifest struct {
+	Job     string         `json:"job"`
+	Host    string         `json:"host"`
+	Sources []string       `json:"sources,omitempty"`
+	Pulled  time.Time      `json:"pulled"`
+	Include []string       `json:"include,omitempty"`
+	Exclude []string       `json:"exclude,omitempty"`
+	Files   []ManifestFile `json:"files"`
+}
+
+// ManifestFile is one pulled result file, relative to the job directory.
+type ManifestFile struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+func newPullCommand() *cobra.Command {
+	pullCmd := &cobra.Command{
+		Use:   "pull [[host:]job] [local_path]",
+		Short: "Pull results",
+		Long: `Pull results from the remote.
+
+With a job, fetches that job's record, log and results into
+<results-path>/jobs/<id>/ and writes a manifest.json listing the pulled files.
+Without one, pulls the results directory into results-path when it is a
+shared corpus, and otherwise the most recent job.`,
+		Args: cobra.MaximumNArgs(2),
+		RunE: pullCommand,
+	}
+	pullCmd.Flags().StringArrayVar(&pullIncludes, "include", nil, "Only pull results matching this glob, e.g. 'reproducers/**' (repeatable)")
+	pullCmd.Flags().StringArrayVar(&pullExcludes, "exclude", nil, "Skip results matching this glob, e.g. 'corpus/**' (repeatable)")
+	pullCmd.Flags().BoolVar(&pullStream, "stream", false, "Transfer as one tar | zstd stream over SSH instead of rsync; resumes when rerun (needs zstd on both ends)")
+	return pullCmd
+}
+
 func pullCommand(cmd *cobra.Command, args []string) error {
+	// A leading host:job address selects the host and pulls that job instead
+	host, jobID := currentHost(), ""
+	if len(args) > 0 {
+		if h, job, ok := parseAddress(args[0]); ok {
+			host, jobID = h, job
+			args = args[1:]
+		}
+	}
+
+	// Use the remote-relative results directory; the local path may be anywhere
+	rel, err := remoteResultsDir()
+	if err != nil {
+		return err
+	}
+
+	// Use SSH client for remote execution
+	client, err := connect(host)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	// A bare argument is a job when the remote has one by that name, else
+	// the local path
+	if jobID == "" && len(args) > 0 && client.jobExists(host.RemotePath, args[0]) {
+		jobID, args = args[0], args[1:]
+	}
+
 	// Use resultsPath flag as default, but allow override with argument
 	if len(args) > 0 {
 		resultsPath = args[0]
 	}
+	if resultsPath == "" {
+		return fmt.Errorf("no local results path: set results-path or pass one")
+	}
+	resultsPath = expandPath(resultsPath)
 
-	fmt.Printf("Pulling results to: %s\n", resultsPath)
+	// Without a job, pull the shared corpus when the results directory is
+	// one, else the most recent job
+	if jobID == "" {
+		if ok, err := client.PullResults(host.RemotePath, rel, resultsPath, pullIncludes, pullExcludes); ok || err != nil {
+			return err
+		}
+		if jobID, err = client.latestJob(host.RemotePath); err != nil {
+			return err
+		}
+		if jobID == "" {
+			return fmt.Errorf("no jobs to pull results from on %s", host.Remote)
+		}
+		fmt.Printf("Pulling the most recent job, %s\n", jobID)
+	}
 
-	// Use SSH client for remote execution
-	var client *SSHClient
-	var err error
+	// A job's results live in its own workspace
+	localPath := filepath.Join(resultsPath, "jobs", jobID)
+	fmt.Printf("Pulling job %s to: %s\n", jobID, localPath)
+	pulled, err := client.PullJob(host.RemotePath, jobID, rel, localPath, pullIncludes, pullExcludes)
+	if err != nil {
+		return err
+	}
 
-	if password != "" {
-		client, err = NewSSHClientWithPassword(remote, password)
-	} else {
-		client, err = NewSSHClient(remote)
+	manifest := PullManifest{
+		Job:     jobID,
+		Host:    host.Name,
+		Pulled:  time.Now().UTC(),
+		Include: pullIncludes,
+		Exclude: pullExcludes,
+		Files:   []ManifestFile{},
+	}
+	if manifest.Host == "" {
+		manifest.Host = host.Remote
+	}
+	for _, dir := range pulled {
+		files, err := listFiles(localPath, filepath.Join(localPath, filepath.FromSlash(dir.Rel)))
+		if err != nil {
+			return err
+		}
+		manifest.Sources = append(manifest.Sources, dir.Source)
+		manifest.Files = append(manifest.Files, files...)
+	}
+	if err := writeManifest(localPath, manifest); err != nil {
+		return err
 	}
+	fmt.Printf("✓ Pulled %d result file(s); see %s\n", len(manifest.Files), filepath.Join(localPath, manifestFile))
+	return nil
+}
+
+// remoteResultsDir returns the results directory relative to the remote
+// project. It defaults to results-path, which only works when that is a
+// relative path inside the project.
+func remoteResultsDir() (string, error) {
+	if remoteResults != "" {
+		rel, ok := projectRelative(remoteResults)
+		if !ok {
+			return "", fmt.Errorf("invalid remote-results-path %q: expected a directory inside the project", remoteResults)
+		}
+		return rel, nil
+	}
+	if rel, ok := projectRelative(expandPath(resultsPath)); ok {
+		return rel, nil
+	}
+	return "", fmt.Errorf("results-path %q is not inside the project; set remote-results-path to the remote results directory", resultsPath)
+}
 
+// pullFilters turns --include and --exclude globs into rsync filter rules.
+// Excludes win over includes; with any include, everything else is skipped
+// and only the directories leading to matches are created.
+func pullFilters(includes, excludes []string) []string {
+	var rules []string
+	for _, p := range excludes {
+		rules = append(rules, "- "+rsyncPattern(p))
+	}
+	if len(includes) == 0 {
+		return rules
+	}
+	rules = append(rules, "+ */")
+	for _, p := range includes {
+		rules = append(rules, "+ "+rsyncPattern(p))
+		// A directory name selects everything below it
+		if !strings.HasSuffix(p, "**") {
+			rules = append(rules, "+ "+strings.TrimSuffix(rsyncPattern(p), "/")+"/**")
+		}
+	}
+	return append(rules, "- *")
+}
+
+// jobExists reports whether the remote has a job record with this ID.
+func (s *SSHClient) jobExists(remotePath, id string) bool {
+	if id == "" || strings.ContainsAny(id, "/ ") {
+		return false
+	}
+	_, err := s.RunCommand(fmt.Sprintf("test -f %s", shellQuote(path.Join(jobDir(remotePath, id), "job.json"))))
+	return err == nil
+}
+
+// listFiles hashes every regular file under dir, with paths relative to base.
+// A missing dir has no files.
+func listFiles(base, dir string) ([]ManifestFile, error) {
+	files := []ManifestFile{}
+	err := filepath.WalkDir(dir, func(p string, d os.DirEntry, err error) error {
+		if os.IsNotExist(err) && p == dir {
+			return filepath.SkipDir
+		}
+		if err != nil || !d.Type().IsRegular() || strings.HasSuffix(p, partSuffix) {
+			return err
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		h := sha256.New()
+		size, err := io.Copy(h, f)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(base, p)
+		files = append(files, ManifestFile{Path: filepath.ToSlash(rel), Size: size, SHA256: hex.EncodeToString(h.Sum(nil))})
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("failed to connect to remote: %w", err)
+		return nil, fmt.Errorf("failed to list files: %w", err)
 	}
-	defer client.Close()
+	sort.Slice(files, func(a, b int) bool { return files[a].Path < files[b].Path })
+	return files, nil
+}
 
-	return client.PullResults(remotePath, resultsPath)
+func writeManifest(dir string, manifest PullManifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, manifestFile), append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	return nil
 }
@@ -0,0 +1,293 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// resultsFile holds a job's parsed results next to its output log.
+const resultsFile = "results.json"
+
+// resultsMarker separates jobs in the output of resultSummaries.
+const resultsMarker = "--osiris-results--"
+
+var resultsJSON bool
+
+// Results is the structured outcome of a fuzzing job, parsed from its output.
+type Results struct {
+	Job     string       `json:"job"`
+	Fuzzer  string       `json:"fuzzer"`
+	Parsed  time.Time    `json:"parsed"`
+	Broken  int          `json:"broken"`
+	Total   int          `json:"total"`
+	Tests   []TestResult `json:"tests"`
+	Summary string       `json:"summary"`
+
+	// Coverage counts CoverageUnit, unique instructions for Echidna and
+	// branches for Medusa
+	Coverage     int             `json:"coverage"`
+	CoverageUnit string          `json:"coverage_unit"`
+	Codehashes   int             `json:"codehashes,omitempty"`
+	CorpusSize   int             `json:"corpus_size"`
+	Calls        int             `json:"calls,omitempty"`
+	Timeline     []CoveragePoint `json:"timeline,omitempty"`
+}
+
+// TestResult is the outcome of one property or assertion. Failed tests carry
+// the (shrunk) call sequence that breaks them.
+type TestResult struct {
+	Name     string   `json:"name"`
+	Status   string   `json:"status"`
+	Sequence []string `json:"sequence,omitempty"`
+	Error    string   `json:"error,omitempty"`
+}
+
+// CoveragePoint records when coverage grew during the campaign, by log
+// timestamp or by time elapsed since the start, whichever the fuzzer prints.
+type CoveragePoint struct {
+	Time     time.Time     `json:"time,omitempty"`
+	Elapsed  time.Duration `json:"elapsed,omitempty"`
+	Coverage int           `json:"coverage"`
+	Corpus   int           `json:"corpus"`
+}
+
+// observe extends the timeline when coverage or the corpus grew.
+func (r *Results) observe(p CoveragePoint) {
+	if n := len(r.Timeline); n > 0 && r.Timeline[n-1].Coverage >= p.Coverage && r.Timeline[n-1].Corpus >= p.Corpus {
+		return
+	}
+	r.Timeline = append(r.Timeline, p)
+	r.Coverage, r.CorpusSize = p.Coverage, p.Corpus
+}
+
+// describe summarizes the results as "3/41 properties broken". Medusa only
+// reports the number of tests at the end of a campaign.
+func (r *Results) describe() string {
+	if r.Total == 0 && r.Broken > 0 {
+		return fmt.Sprintf("%d properties broken", r.Broken)
+	}
+	if r.Total == 0 {
+		return "no properties reported yet"
+	}
+	return fmt.Sprintf("%d/%d properties broken", r.Broken, r.Total)
+}
+
+func newResultsCommand() *cobra.Command {
+	resultsCmd := &cobra.Command{
+		Use:   "results <[host:]job>",
+		Short: "Show the parsed fuzzing results of a job",
+		Long: `Parse a job's output into per-property results: passed, failed or error,
+the shrunk call sequence of every broken property, coverage, corpus size and
+the coverage timeline. The results are saved as results.json in the job's
+directory on the remote, so pull <job> fetches them too.`,
+		Args: cobra.ExactArgs(1),
+		RunE: resultsCommand,
+	}
+	resultsCmd.Flags().BoolVar(&resultsJSON, "json", false, "Print the results as JSON")
+	return resultsCmd
+}
+
+func resultsCommand(cmd *cobra.Command, args []string) error {
+	h, id := splitAddress(args[0])
+	client, err := connect(h)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	results, err := client.JobResults(h.RemotePath, id)
+	if err != nil {
+		return err
+	}
+	if resultsJSON {
+		data, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+	results.print(os.Stdout)
+	return nil
+}
+
+// JobResults parses a job's output log and saves the results in its job
+// directory.
+func (s *SSHClient) JobResults(remotePath, id string) (*Results, error) {
+	dir := jobDir(remotePath, id)
+	meta, err := s.RunCommand(fmt.Sprintf("cat %s", shellQuote(path.Join(dir, "job.json"))))
+	if err != nil {
+		return nil, fmt.Errorf("job %s not found", id)
+	}
+	var job Job
+	if err := json.Unmarshal([]byte(meta), &job); err != nil {
+		return nil, fmt.Errorf("failed to parse job metadata: %w", err)
+	}
+	registry, err := loadAdapters()
+	if err != nil {
+		return nil, err
+	}
+	adapter := adapterFor(registry, job)
+	if adapter == nil {
+		return nil, fmt.Errorf("no fuzzer adapter for %q (see osiris-lite fuzzers)", job.Command)
+	}
+
+	var log bytes.Buffer
+	if err := s.RunCommandWithOutput(fmt.Sprintf("cat %s", shellQuote(path.Join(dir, "output.log"))), nil, &log); err != nil {
+		return nil, fmt.Errorf("job %s has no output yet", id)
+	}
+	results, err := adapter.Parse(&log)
+	if err != nil {
+		return nil, err
+	}
+	results.Job = id
+	results.Parsed = time.Now().UTC()
+	results.Summary = results.describe()
+
+	data, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.RunCommandWithInput(fmt.Sprintf("cat > %s", shellQuote(path.Join(dir, resultsFile))), bytes.NewReader(append(data, '\n'))); err != nil {
+		return nil, fmt.Errorf("failed to save results: %w", err)
+	}
+	return results, nil
+}
+
+// resultSummaries reads just enough of each job's log to tell how many
+// properties it has broken, keyed by job ID. Jobs without a parser or output
+// are left out.
+func (s *SSHClient) resultSummaries(remotePath string, jobs []JobState) map[string]*Results {
+	summaries := make(map[string]*Results)
+	registry, err := loadAdapters()
+	if err != nil {
+		return summaries
+	}
+	adapters := make(map[string]FuzzerAdapter)
+
+	var script strings.Builder
+	for _, j := range jobs {
+		adapter := adapterFor(registry, j.Job)
+		if adapter == nil || j.Status == "queued" {
+			continue
+		}
+		spec := adapter.Spec()
+		if spec.Progress == "" && spec.Latest == "" {
+			continue
+		}
+		adapters[j.ID] = adapter
+		// The patterns reach awk through the environment, so no character in
+		// them needs escaping
+		log := path.Join(jobDir(remotePath, j.ID), "output.log")
+		awk := `$0 ~ ENVIRON["P"] { print }`
+		switch {
+		case spec.Progress == "":
+			awk = `$0 ~ ENVIRON["L"] { s = $0 } END { if (s != "") print s }`
+		case spec.Latest != "":
+			awk = `$0 ~ ENVIRON["L"] { s = $0; next } $0 ~ ENVIRON["P"] { print } END { if (s != "") print s }`
+		}
+		fmt.Fprintf(&script, "echo %s %s\n", resultsMarker, shellQuote(j.ID))
+		fmt.Fprintf(&script, "[ -f %s ] && P=%s L=%s awk %s %s\n", shellQuote(log), shellQuote(spec.Progress), shellQuote(spec.Latest), shellQuote(awk), shellQuote(log))
+	}
+	if script.Len() == 0 {
+		return summaries
+	}
+	script.WriteString("true\n")
+	output, err := s.RunCommandWithInput("sh", strings.NewReader(script.String()))
+	if err != nil {
+		return summaries
+	}
+
+	for _, chunk := range strings.Split(output, resultsMarker+" ")[1:] {
+		id, excerpt, _ := strings.Cut(chunk, "\n")
+		adapter, ok := adapters[id]
+		if !ok {
+			continue
+		}
+		if results, err := adapter.Parse(strings.NewReader(excerpt)); err == nil && (results.Total > 0 || results.Broken > 0) {
+			summaries[id] = results
+		}
+	}
+	return summaries
+}
+
+func (r *Results) print(out io.Writer) {
+	fmt.Fprintf(out, "Job %s (%s): %s\n\n", r.Job, r.Fuzzer, r.describe())
+
+	if len(r.Tests) > 0 {
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "TEST\tSTATUS")
+		for _, t := range r.Tests {
+			marker := "✓"
+			if t.Status == "failed" {
+				marker = "✗"
+			} else if t.Status == "error" {
+				marker = "⚠"
+			}
+			fmt.Fprintf(w, "%s\t%s %s\n", t.Name, marker, t.Status)
+		}
+		w.Flush()
+	}
+
+	for _, t := range r.Tests {
+		if t.Status == "passed" || (len(t.Sequence) == 0 && t.Error == "") {
+			continue
+		}
+		marker := "✗"
+		if t.Status == "error" {
+			marker = "⚠"
+		}
+		fmt.Fprintf(out, "\n%s %s", marker, t.Name)
+		if t.Error != "" {
+			fmt.Fprintf(out, ": %s", t.Error)
+		}
+		fmt.Fprintln(out)
+		if len(t.Sequence) > 0 {
+			fmt.Fprintln(out, "  Call sequence:")
+			for _, call := range t.Sequence {
+				fmt.Fprintf(out, "    %s\n", call)
+			}
+		}
+	}
+
+	fmt.Fprintln(out)
+	if r.CoverageUnit != "" {
+		fmt.Fprintf(out, "Coverage: %d %s", r.Coverage, r.CoverageUnit)
+		if r.Codehashes > 0 {
+			fmt.Fprintf(out, " across %d codehashes", r.Codehashes)
+		}
+		fmt.Fprintf(out, "\nCorpus size: %d\n", r.CorpusSize)
+	}
+	if r.Calls > 0 {
+		fmt.Fprintf(out, "Total calls: %d\n", r.Calls)
+	}
+
+	// The most recent coverage growth; --json has the full timeline
+	if len(r.Timeline) > 0 {
+		fmt.Fprintln(out, "\nCoverage timeline:")
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(w, "TIME\t%s\tCORPUS\n", strings.ToUpper(r.CoverageUnit))
+		start := r.Timeline[0].Time
+		points := r.Timeline
+		if len(points) > 10 {
+			points = points[len(points)-10:]
+		}
+		for _, p := range points {
+			elapsed := p.Elapsed
+			if !p.Time.IsZero() {
+				elapsed = p.Time.Sub(start)
+			}
+			fmt.Fprintf(w, "+%s\t%d\t%d\n", elapsed, p.Coverage, p.Corpus)
+		}
+		w.Flush()
+	}
+}
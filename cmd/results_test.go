@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// parseFixture runs a parser over a captured log in testdata.
+func parseFixture(t *testing.T, parse func(io.Reader) (*Results, error), name string) *Results {
+	t.Helper()
+	f, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	results, err := parse(f)
+	if err != nil {
+		t.Fatalf("parse %s: %v", name, err)
+	}
+	return results
+}
+
+// checkResults compares everything but the parse time and the coverage
+// timeline, of which only the number of points is checked.
+func checkResults(t *testing.T, got, want *Results, points int) {
+	t.Helper()
+	if got.Fuzzer != want.Fuzzer {
+		t.Errorf("fuzzer = %q, want %q", got.Fuzzer, want.Fuzzer)
+	}
+	if got.Broken != want.Broken || got.Total != want.Total {
+		t.Errorf("broken/total = %d/%d, want %d/%d", got.Broken, got.Total, want.Broken, want.Total)
+	}
+	if !reflect.DeepEqual(got.Tests, want.Tests) {
+		t.Errorf("tests = %#v\nwant %#v", got.Tests, want.Tests)
+	}
+	if got.Coverage != want.Coverage || got.CorpusSize != want.CorpusSize {
+		t.Errorf("coverage/corpus = %d/%d, want %d/%d", got.Coverage, got.CorpusSize, want.Coverage, want.CorpusSize)
+	}
+	if got.Codehashes != want.Codehashes || got.Calls != want.Calls {
+		t.Errorf("codehashes/calls = %d/%d, want %d/%d", got.Codehashes, got.Calls, want.Codehashes, want.Calls)
+	}
+	if len(got.Timeline) != points {
+		t.Errorf("timeline has %d points, want %d", len(got.Timeline), points)
+	}
+}
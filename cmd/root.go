@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
@@ -14,16 +15,26 @@ var (
 	remote         string
 	remotePath     string
 	resultsPath    string
+	remoteResults  string
 	dockerfilePath string
 	password       string
 	image          string
 	container      = "osiris-runner"
+	capacity       int
+	hostName       string
+	profileName    string
+	hosts          map[string]Host
+
+	configErr error
 
 	// rootCmd represents the base command when called without any subcommands
 	rootCmd = &cobra.Command{
 		Use:     "osiris-lite",
 		Short:   "Remote fuzzing workflow management",
 		Version: "1.0.1", // Change this to the version of the CLI on release
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return configErr
+		},
 	}
 )
 
@@ -33,6 +44,9 @@ func init() {
 	// Global config file flag
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.osiris.yaml)")
 
+	// Profile selection within the config file
+	rootCmd.PersistentFlags().StringVar(&profileName, "profile", "", "Config profile to use (inherits from the default profile)")
+
 	// Version flag
 	rootCmd.PersistentFlags().Bool("version", false, "Show version information")
 
@@ -40,48 +54,49 @@ func init() {
 	rootCmd.PersistentFlags().StringVarP(&remote, "remote", "r", "", "Remote server (SSH config alias)")
 	rootCmd.PersistentFlags().StringVar(&remotePath, "remote-path", "", "Remote working directory")
 	rootCmd.PersistentFlags().StringVar(&resultsPath, "results-path", "", "Local directory for pulling results")
+	rootCmd.PersistentFlags().StringVar(&remoteResults, "remote-results-path", "", "Results directory relative to the remote project (default: results-path when it is relative)")
 	rootCmd.PersistentFlags().StringVarP(&dockerfilePath, "dockerfile", "d", "test/enigma-dark-invariants/remote/DOCKERFILE", "Path to Dockerfile relative to remote-path")
 	rootCmd.PersistentFlags().StringVarP(&password, "password", "p", "", "Password for SSH authentication (optional)")
 	rootCmd.PersistentFlags().StringVar(&image, "image", "osiris-fuzzer", "Docker image name")
 	rootCmd.PersistentFlags().StringVar(&container, "container", "osiris-runner", "Container name")
+	rootCmd.PersistentFlags().StringVar(&hostName, "host", "", "Host from the hosts config section to target; run also accepts auto or tag=<tag>")
 
 	// Bind flags to viper
 	viper.BindPFlag("remote", rootCmd.PersistentFlags().Lookup("remote"))
 	viper.BindPFlag("remote-path", rootCmd.PersistentFlags().Lookup("remote-path"))
 	viper.BindPFlag("results-path", rootCmd.PersistentFlags().Lookup("results-path"))
+	viper.BindPFlag("remote-results-path", rootCmd.PersistentFlags().Lookup("remote-results-path"))
 	viper.BindPFlag("dockerfile", rootCmd.PersistentFlags().Lookup("dockerfile"))
 	viper.BindPFlag("password", rootCmd.PersistentFlags().Lookup("password"))
 	viper.BindPFlag("image", rootCmd.PersistentFlags().Lookup("image"))
 	viper.BindPFlag("container", rootCmd.PersistentFlags().Lookup("container"))
+	viper.BindPFlag("host", rootCmd.PersistentFlags().Lookup("host"))
+	viper.BindPFlag("profile", rootCmd.PersistentFlags().Lookup("profile"))
 
 	// Add subcommands
 	rootCmd.AddCommand(
+		newRunCommand(),
+		newBuildCommand(),
+		newSyncCommand(),
+		newStatusCommand(),
 		&cobra.Command{
-			Use:   "run [command]",
-			Short: "Run command in Docker on remote",
-			Args:  cobra.MinimumNArgs(1),
-			RunE:  runCommand,
-		},
-		&cobra.Command{
-			Use:   "status",
-			Short: "Check active jobs",
-			RunE:  statusCommand,
-		},
-		&cobra.Command{
-			Use:   "kill [container_id|all]",
+			Use:   "kill [[host:]container_id|[host:]all]",
 			Short: "Kill jobs",
 			RunE:  killCommand,
 		},
+		newPullCommand(),
+		newResultsCommand(),
+		newPushCorpusCommand(),
+		newCorpusCommand(),
 		&cobra.Command{
-			Use:   "pull [optional_path]",
-			Short: "Pull results",
-			RunE:  pullCommand,
-		},
-		&cobra.Command{
-			Use:   "logs [container_id]",
+			Use:   "logs [[host:]container_id]",
 			Short: "Connect to container logs",
 			RunE:  logsCommand,
 		},
+		newScheduleCommand(),
+		newJobsCommand(),
+		newFuzzersCommand(),
+		newConfigCommand(),
 	)
 }
 
@@ -107,15 +122,38 @@ func initConfig() {
 	viper.AutomaticEnv() // read in environment variables that match
 
 	// Bind specific environment variables
-	viper.BindEnv("remote", "OSIRIS_REMOTE")
-	viper.BindEnv("password", "OSIRIS_REMOTE_PASSWORD")
-	viper.BindEnv("remote-path", "OSIRIS_REMOTE_PATH")
-	viper.BindEnv("image", "OSIRIS_IMAGE")
-	viper.BindEnv("container", "OSIRIS_CONTAINER")
+	for key, env := range envBindings {
+		viper.BindEnv(key, env)
+	}
 
-	// If a config file is found, read it in.
+	// If a config file is found, read it in. Only a missing home config is
+	// fine; a file that does not parse must not leave commands on defaults
+	var notFound viper.ConfigFileNotFoundError
 	if err := viper.ReadInConfig(); err == nil {
 		fmt.Fprintln(os.Stderr, "Using config file:", viper.ConfigFileUsed())
+		homeConfigFile = viper.ConfigFileUsed()
+	} else if !errors.As(err, &notFound) {
+		setConfigErr(fmt.Errorf("failed to read config file %s: %w", viper.ConfigFileUsed(), err))
+	}
+
+	// Layer the project's own .osiris.yaml over the home config
+	if project := discoverProjectConfig(); project != "" && !sameFile(project, homeConfigFile) {
+		viper.SetConfigFile(project)
+		if err := viper.MergeInConfig(); err != nil {
+			setConfigErr(fmt.Errorf("failed to read project config %s: %w", project, err))
+		}
+		fmt.Fprintln(os.Stderr, "Using project config file:", project)
+		projectConfigFile = project
+		if fileSets(project, "password") {
+			fmt.Fprintln(os.Stderr, "Warning: project config sets a password; keep credentials in the home config or OSIRIS_REMOTE_PASSWORD")
+		}
+	}
+
+	// Layer the selected profile over the file values
+	profileName = getWithoutAutoEnv("profile")
+	setConfigErr(applyProfile(profileName))
+	if profileName != "" {
+		fmt.Fprintln(os.Stderr, "Using profile:", profileName)
 	}
 
 	// Update variables from viper config
@@ -128,6 +166,9 @@ func initConfig() {
 	if viper.IsSet("results-path") {
 		resultsPath = viper.GetString("results-path")
 	}
+	if viper.IsSet("remote-results-path") {
+		remoteResults = viper.GetString("remote-results-path")
+	}
 	if viper.IsSet("dockerfile") {
 		dockerfilePath = viper.GetString("dockerfile")
 	}
@@ -140,6 +181,49 @@ func initConfig() {
 	if viper.IsSet("container") {
 		container = viper.GetString("container")
 	}
+	if viper.IsSet("capacity") {
+		capacity = viper.GetInt("capacity")
+	}
+	hostName = getWithoutAutoEnv("host")
+
+	// Load the fleet and point the remote settings at the selected host
+	var err error
+	hosts, err = loadHosts()
+	setConfigErr(err)
+	// Selectors like "auto" are resolved by run through live placement
+	if hostName != "" && !isHostSelector(hostName) {
+		if h, err := lookupHost(hostName); err != nil {
+			setConfigErr(err)
+		} else {
+			selectHost(h)
+		}
+	}
+}
+
+// setConfigErr keeps the first problem found while loading the config. It is
+// reported before any command runs, except config validate which lists it.
+func setConfigErr(err error) {
+	if configErr == nil {
+		configErr = err
+	}
+}
+
+// selectHost makes h the current host. Connection settings given by flag or
+// environment variable still win over the host entry.
+func selectHost(h Host) {
+	if !setExplicitly("remote") {
+		remote = h.Remote
+	}
+	if !setExplicitly("remote-path") {
+		remotePath = h.RemotePath
+	}
+	if !setExplicitly("password") {
+		password = h.Password
+	}
+	if !setExplicitly("capacity") {
+		capacity = h.Capacity
+	}
+	hostName = h.Name
 }
 
 func Execute() error {
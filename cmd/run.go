@@ -3,42 +3,298 @@ package cmd
 import (
 	"fmt"
 	"os"
-	"os/exec"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 )
 
+var (
+	matrixSpecs     []string
+	parallel        int
+	placementReason string
+
+	// Fuzzer overrides, applied by the adapters that support them
+	fuzzTimeout      time.Duration
+	fuzzWorkers      int
+	fuzzCorpusDir    string
+	fuzzRuns         int
+	fuzzDepth        int
+	fuzzFailOnRevert *bool
+	fuzzerSettings   *FuzzerSettings
+)
+
+func newRunCommand() *cobra.Command {
+	runCmd := &cobra.Command{
+		Use:   "run [command]",
+		Short: "Run command in Docker on remote",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 && fuzzerName == "" {
+				return fmt.Errorf("requires a command, or --fuzzer to run a fuzzer adapter's command")
+			}
+			return nil
+		},
+		RunE: runCommand,
+	}
+	runCmd.Flags().StringVar(&fuzzerName, "fuzzer", "", "Run this fuzzer adapter's command, with the arguments as {{args}} (see osiris-lite fuzzers)")
+	runCmd.Flags().StringArrayVar(&matrixSpecs, "matrix", nil, "Sweep a parameter as name=1..8 or name=a,b,c; use {{name}} in the command (repeatable)")
+	runCmd.Flags().IntVar(&distributed, "distributed", 0, "Run N instances on the current host as one campaign that exchanges new corpus entries between them; use {{instance}} in the command")
+	runCmd.Flags().DurationVar(&exchangeInterval, "exchange-interval", 5*time.Minute, "Time between corpus exchanges for --distributed")
+	runCmd.Flags().IntVar(&parallel, "parallel", 0, "Maximum concurrent matrix jobs (default: capacity from config, else remote core count)")
+	runCmd.Flags().DurationVar(&fuzzTimeout, "timeout", 0, "Medusa: stop fuzzing after this long, overriding medusa.json")
+	runCmd.Flags().IntVar(&fuzzWorkers, "workers", 0, "Medusa: number of fuzzing workers, overriding medusa.json")
+	runCmd.Flags().StringVar(&fuzzCorpusDir, "corpus-dir", "", "Medusa: corpus directory relative to the project, overriding medusa.json")
+	runCmd.Flags().IntVar(&fuzzRuns, "runs", 0, "Foundry: invariant runs, overriding foundry.toml")
+	runCmd.Flags().IntVar(&fuzzDepth, "depth", 0, "Foundry: calls per invariant run, overriding foundry.toml")
+	runCmd.Flags().Bool("fail-on-revert", false, "Foundry: fail invariants on reverts, overriding foundry.toml")
+	runCmd.Flags().StringArrayVarP(&envFlags, "env", "e", nil, "Set an environment variable in the container as KEY=VALUE (repeatable)")
+	runCmd.Flags().StringArrayVar(&envFiles, "env-file", nil, "Read container environment variables from a local file; values are treated as secrets (repeatable)")
+	runCmd.Flags().StringVar(&runRef, "ref", "", "Sync a clean checkout of this commit or branch, with submodules, instead of the working tree")
+	runCmd.Flags().StringVar(&seedCorpus, "seed-corpus", "", "Upload this local corpus to the corpus directory before starting; only new content is sent")
+	runCmd.Flags().StringVar(&corpusDest, "seed-dest", "", "Corpus directory for --seed-corpus, relative to the project (default: the fuzzer config's corpus directory, else the results directory)")
+	runCmd.Flags().StringArrayVar(&sharedCorpusFlags, "shared-corpus", nil, "Share this project directory between jobs, persisted under .osiris/corpus on the remote (repeatable)")
+	runCmd.Flags().BoolVar(&runPreview, "preview", false, "Show what the sync would change and exit without syncing or running")
+	runCmd.Flags().BoolVarP(&syncYes, "yes", "y", false, "Delete remote files beyond the sync delete threshold without asking")
+	runCmd.Flags().BoolVar(&rebuild, "rebuild", false, "Rebuild the Docker image even if the build context is unchanged")
+	addDockerFlags(runCmd.Flags())
+	addBuildFlags(runCmd.Flags())
+	return runCmd
+}
+
 func runCommand(cmd *cobra.Command, args []string) error {
+	if parallel < 0 {
+		return fmt.Errorf("invalid --parallel %d: expected a positive number of jobs", parallel)
+	}
+
+	// Placement must decide where the job runs, or it would record one host
+	// and run on another
+	if isHostSelector(hostName) {
+		for _, key := range []string{"remote", "remote-path"} {
+			if setExplicitly(key) {
+				_, origin := configLayer(key)
+				return fmt.Errorf("%s from %s cannot be combined with --host %s, which picks the host", key, origin, hostName)
+			}
+		}
+	}
+
+	registry, err := loadAdapters()
+	if err != nil {
+		return err
+	}
 	command := strings.Join(args, " ")
-	fmt.Printf("Running: %s\n", command)
+	adapter := matchAdapter(registry, command)
+	if fuzzerName != "" {
+		if adapter, err = lookupAdapter(registry, fuzzerName); err != nil {
+			return err
+		}
+		if command, err = fuzzerCommand(adapter, args); err != nil {
+			return err
+		}
+	}
+	if cmd.Flags().Changed("fail-on-revert") {
+		value, _ := cmd.Flags().GetBool("fail-on-revert")
+		fuzzFailOnRevert = &value
+	}
 
-	// Sync files using rsync (keeping this as external process)
-	fmt.Println("Syncing files...")
-	syncCmd := exec.Command("rsync", "-avz", "--delete",
-		"--exclude=.git", "--exclude=out", "--exclude=cache", "--exclude=osiris-lite",
-		"-e", "ssh -F "+os.Getenv("HOME")+"/.ssh/config",
-		"./", remote+":"+remotePath)
-	syncCmd.Stdout = os.Stdout
-	syncCmd.Stderr = os.Stderr
-	if err := syncCmd.Run(); err != nil {
+	env, err := resolveEnv()
+	if err != nil {
+		return err
+	}
+	dockerOpts, err := loadDockerOptions()
+	if err != nil {
 		return err
 	}
 
-	// Use SSH client for remote execution
-	var client *SSHClient
-	var err error
+	buildOpts, err := loadBuildOptions()
+	if err != nil {
+		return err
+	}
+	wsOpts, err := loadWorkspaceOptions()
+	if err != nil {
+		return err
+	}
+	syncOpts, err := loadSyncOptions()
+	if err != nil {
+		return err
+	}
 
-	if password != "" {
-		client, err = NewSSHClientWithPassword(remote, password)
+	// Sync either the working tree or a clean export of --ref, and record
+	// which code version the job runs
+	source := "."
+	var gitInfo *GitInfo
+	if runRef != "" {
+		root, dir, info, err := exportRef(runRef)
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(root)
+		source, gitInfo = dir, info
+		fmt.Printf("Using %s at %s\n", runRef, info.Commit[:12])
 	} else {
-		client, err = NewSSHClient(remote)
+		if gitInfo, err = workingTreeInfo(); err != nil {
+			return err
+		}
+		if gitInfo != nil && gitInfo.Dirty {
+			fmt.Printf("⚠ Uncommitted changes will be synced; the job records them as a diff against %s (use --ref for a clean checkout)\n", gitInfo.Commit[:12])
+		}
 	}
 
+	// The fuzzer's adapter applies the override flags and records what pull,
+	// kill and results need to know about the job
+	if adapter != nil {
+		if fuzzerSettings, command, err = adapter.Prepare(source, command, env); err != nil {
+			return err
+		}
+	} else if err := checkOverrides(""); err != nil {
+		return err
+	}
+
+	// Seeds go where the fuzzer reads its corpus from
+	var seedTo string
+	if seedCorpus != "" {
+		if seedTo, err = seedDest(source, fuzzerSettings); err != nil {
+			return err
+		}
+	}
+
+	// Tag the image by its build context so unchanged images are not rebuilt
+	ref, err := contextImage(source, buildOpts, syncOpts)
 	if err != nil {
-		return fmt.Errorf("failed to connect to remote: %w", err)
+		return err
+	}
+
+	var axes []matrixAxis
+	if distributed > 0 && len(matrixSpecs) > 0 {
+		return fmt.Errorf("--distributed cannot be combined with --matrix")
+	}
+	// The exchange copies entries between containers on one remote
+	if distributed > 0 && isHostSelector(hostName) {
+		return fmt.Errorf("--distributed runs every instance on one host; select it with --host <name> instead of %s", hostName)
+	}
+	if len(matrixSpecs) > 0 {
+		if axes, err = parseMatrix(matrixSpecs); err != nil {
+			return err
+		}
+		if err := checkTemplate(command, axes); err != nil {
+			return err
+		}
+	}
+
+	// Resolve --host auto / tag=... to a concrete host before syncing
+	if isHostSelector(hostName) {
+		h, reason, err := placeJob(hostName)
+		if err != nil {
+			return err
+		}
+		selectHost(h)
+		placementReason = reason
+		fmt.Printf("Placed on %s: %s\n", h.Name, reason)
+	}
+
+	dest := remote + ":" + remotePath
+	if runPreview {
+		summary, err := previewSync(source, dest, syncOpts)
+		if err != nil {
+			return err
+		}
+		summary.print(os.Stdout)
+		return nil
+	}
+
+	fmt.Printf("Running: %s\n", maskSecrets(command))
+
+	fmt.Println("Syncing files...")
+	if err := pushTree(source, dest, syncOpts, os.Stdout, true); err != nil {
+		return err
+	}
+
+	// Use SSH client for remote execution
+	client, err := connect(currentHost())
+	if err != nil {
+		return err
 	}
 	defer client.Close()
 
-	return client.RunRemoteCommand(remotePath, image, container, command)
+	// Apply the configured workspace retention policy before adding more
+	if wsOpts.retains() {
+		if pruned, _, err := client.PruneWorkspaces(remotePath, wsOpts, false); err != nil {
+			fmt.Printf("⚠ %v\n", err)
+		} else if pruned > 0 {
+			fmt.Printf("Pruned %d finished job workspace(s)\n", pruned)
+		}
+	}
+
+	// Seed after syncing so --delete cannot remove it before jobs snapshot it
+	if seedCorpus != "" {
+		if seedRecord, err = client.PushCorpus(remotePath, seedCorpus, seedTo, wsOpts); err != nil {
+			return err
+		}
+	}
+
+	if distributed > 0 {
+		return runDistributed(client, distributed, ref, command, env, dockerOpts, buildOpts, gitInfo, wsOpts)
+	}
+	if len(axes) > 0 {
+		return runMatrix(client, ref, command, axes, env, dockerOpts, buildOpts, gitInfo, wsOpts)
+	}
+
+	job := newJob(container, ref, command)
+	job.Env = env.masked()
+	job.Docker = dockerOpts
+	job.Build = buildOpts
+	job.Git = gitInfo
+	job.SharedCorpus = wsOpts.SharedCorpus
+	return client.RunRemoteCommand(remotePath, job, env)
+}
+
+// runMatrix expands the command over the matrix into a job set and hands it to
+// a detached runner on the remote that respects the host's capacity.
+func runMatrix(client *SSHClient, ref, command string, axes []matrixAxis, env jobEnv, dockerOpts DockerOptions, buildOpts BuildOptions, gitInfo *GitInfo, wsOpts WorkspaceOptions) error {
+	limit := parallel
+	if limit == 0 {
+		limit = capacity
+	}
+	if limit == 0 {
+		n, err := client.RemoteCapacity()
+		if err != nil {
+			return err
+		}
+		limit = n
+	}
+
+	// Each Medusa job keeps its workers busy, so fewer jobs fit at once
+	if parallel == 0 && fuzzerSettings != nil && fuzzerSettings.Workers > 1 {
+		limit = max(1, limit/fuzzerSettings.Workers)
+	}
+
+	id, err := client.BuildImage(remotePath, ref, buildOpts, rebuild, os.Stdout)
+	if err != nil {
+		return err
+	}
+
+	setID := "set-" + randomSuffix()
+	var jobs []Job
+	for _, params := range expandMatrix(axes) {
+		job := newJob(container, ref, renderTemplate(command, params))
+		job.Set = setID
+		job.Params = params
+		job.Env = env.masked()
+		job.ImageID = id
+		job.Docker = dockerOpts
+		job.Build = buildOpts
+		job.Git = gitInfo
+		job.SharedCorpus = wsOpts.SharedCorpus
+		if err := client.CreateJob(remotePath, job); err != nil {
+			return err
+		}
+		jobs = append(jobs, job)
+	}
+
+	if err := client.StartJobSet(remotePath, setID, jobs, limit, env); err != nil {
+		return err
+	}
+
+	fmt.Printf("Started job set %s: %d jobs, at most %d at a time\n", setID, len(jobs), limit)
+	fmt.Printf("Follow progress with: osiris-lite jobs --set %s\n", setID)
+	return nil
 }
@@ -0,0 +1,450 @@
+package cmd
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// stateDir is the directory under remote-path where osiris-lite keeps its
+// remote-side state. It is excluded from every sync so --delete never touches it.
+const stateDir = ".osiris"
+
+var (
+	scheduleRepo     string
+	scheduleRef      string
+	scheduleName     string
+	scheduleDuration time.Duration
+	historyLimit     int
+
+	cronFieldPattern  = regexp.MustCompile(`^[0-9A-Za-z*/,\-]+$`)
+	scheduleIDPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.\-]*$`)
+)
+
+// Schedule describes a recurring campaign run by cron on the remote.
+type Schedule struct {
+	ID         string        `json:"id"`
+	Cron       string        `json:"cron"`
+	Command    string        `json:"command"`
+	Repo       string        `json:"repo"`
+	Ref        string        `json:"ref"`
+	Duration   string        `json:"duration,omitempty"`
+	Image      string        `json:"image"`
+	Container  string        `json:"container"`
+	Dockerfile string        `json:"dockerfile"`
+	Docker     DockerOptions `json:"docker"`
+	Created    time.Time     `json:"created"`
+}
+
+// ScheduleRun is one line of a schedule's run history.
+type ScheduleRun struct {
+	Start    string
+	End      string
+	ExitCode string
+	Commit   string
+	Log      string
+}
+
+func newScheduleCommand() *cobra.Command {
+	scheduleCmd := &cobra.Command{
+		Use:   "schedule",
+		Short: "Manage recurring campaigns run by cron on the remote",
+	}
+
+	addCmd := &cobra.Command{
+		Use:   "add \"<cron>\" -- [command]",
+		Short: "Add a schedule (cron expression uses the remote's timezone)",
+		Args:  cobra.MinimumNArgs(2),
+		RunE:  scheduleAddCommand,
+	}
+	addCmd.Flags().StringVar(&scheduleRepo, "repo", "", "Git repository URL the remote clones (default: local 'origin' URL)")
+	addCmd.Flags().StringVar(&scheduleRef, "ref", "main", "Git branch, tag or commit to check out before each run")
+	addCmd.Flags().StringVar(&scheduleName, "name", "", "Schedule ID (default: generated)")
+	addCmd.Flags().DurationVar(&scheduleDuration, "duration", 0, "Stop each run gracefully after this long (e.g. 8h)")
+	addDockerFlags(addCmd.Flags())
+
+	historyCmd := &cobra.Command{
+		Use:   "history [schedule_id]",
+		Short: "Show the run history of a schedule",
+		Args:  cobra.ExactArgs(1),
+		RunE:  scheduleHistoryCommand,
+	}
+	historyCmd.Flags().IntVarP(&historyLimit, "limit", "n", 20, "Number of most recent runs to show")
+
+	scheduleCmd.AddCommand(
+		addCmd,
+		&cobra.Command{
+			Use:   "list",
+			Short: "List schedules and their last run",
+			RunE:  scheduleListCommand,
+		},
+		&cobra.Command{
+			Use:   "remove [schedule_id]",
+			Short: "Remove a schedule and its crontab entry",
+			Args:  cobra.ExactArgs(1),
+			RunE:  scheduleRemoveCommand,
+		},
+		historyCmd,
+	)
+	return scheduleCmd
+}
+
+func scheduleAddCommand(cmd *cobra.Command, args []string) error {
+	if remotePath == "" {
+		return fmt.Errorf("remote-path is required for schedules")
+	}
+
+	// Everything after the cron expression is the command; "--" is optional
+	cronExpr := args[0]
+	command := strings.Join(args[1:], " ")
+	if err := validateCron(cronExpr); err != nil {
+		return err
+	}
+
+	repo := scheduleRepo
+	if repo == "" {
+		out, err := exec.Command("git", "remote", "get-url", "origin").Output()
+		if err != nil {
+			return fmt.Errorf("no --repo given and no local 'origin' remote found: %w", err)
+		}
+		repo = strings.TrimSpace(string(out))
+	}
+
+	dockerOpts, err := loadDockerOptions()
+	if err != nil {
+		return err
+	}
+
+	id := scheduleName
+	if id == "" {
+		id = "sched-" + randomSuffix()
+	}
+	if !scheduleIDPattern.MatchString(id) {
+		return fmt.Errorf("invalid schedule ID %q: use letters, digits, '.', '_' and '-'", id)
+	}
+
+	sched := Schedule{
+		ID:         id,
+		Cron:       cronExpr,
+		Command:    command,
+		Repo:       repo,
+		Ref:        scheduleRef,
+		Image:      image,
+		Container:  container,
+		Dockerfile: dockerfilePath,
+		Docker:     dockerOpts,
+		Created:    time.Now().UTC(),
+	}
+	if scheduleDuration > 0 {
+		sched.Duration = scheduleDuration.String()
+	}
+
+	client, err := connect(currentHost())
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if err := client.AddSchedule(remotePath, sched); err != nil {
+		return err
+	}
+
+	fmt.Printf("Added schedule %s (%s) running: %s\n", sched.ID, sched.Cron, sched.Command)
+	return nil
+}
+
+func scheduleListCommand(cmd *cobra.Command, args []string) error {
+	client, err := connect(currentHost())
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	schedules, lastRuns, err := client.ListSchedules(remotePath)
+	if err != nil {
+		return err
+	}
+	if len(schedules) == 0 {
+		fmt.Println("No schedules")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tCRON\tREF\tDURATION\tLAST RUN\tEXIT\tCOMMAND")
+	for _, s := range schedules {
+		last, code := "never", "-"
+		if run, ok := lastRuns[s.ID]; ok {
+			last, code = run.Start, run.ExitCode
+		}
+		duration := s.Duration
+		if duration == "" {
+			duration = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", s.ID, s.Cron, s.Ref, duration, last, code, s.Command)
+	}
+	return w.Flush()
+}
+
+func scheduleRemoveCommand(cmd *cobra.Command, args []string) error {
+	client, err := connect(currentHost())
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if err := client.RemoveSchedule(remotePath, args[0]); err != nil {
+		return err
+	}
+
+	fmt.Printf("Removed schedule: %s\n", args[0])
+	return nil
+}
+
+func scheduleHistoryCommand(cmd *cobra.Command, args []string) error {
+	client, err := connect(currentHost())
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	runs, err := client.ScheduleHistory(remotePath, args[0], historyLimit)
+	if err != nil {
+		return err
+	}
+	if len(runs) == 0 {
+		fmt.Printf("Schedule %s has not run yet\n", args[0])
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "START\tEND\tEXIT\tCOMMIT\tLOG")
+	for _, r := range runs {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", r.Start, r.End, r.ExitCode, r.Commit, r.Log)
+	}
+	return w.Flush()
+}
+
+// validateCron performs a light sanity check; cron itself is the final judge.
+func validateCron(expr string) error {
+	if strings.HasPrefix(expr, "@") {
+		switch expr {
+		case "@yearly", "@annually", "@monthly", "@weekly", "@daily", "@midnight", "@hourly":
+			return nil
+		}
+		return fmt.Errorf("unsupported cron macro %q", expr)
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return fmt.Errorf("cron expression %q must have 5 fields (minute hour day month weekday)", expr)
+	}
+	for _, f := range fields {
+		if !cronFieldPattern.MatchString(f) {
+			return fmt.Errorf("invalid cron field %q in %q", f, expr)
+		}
+	}
+	return nil
+}
+
+// randomSuffix returns a short random hex string for generated IDs.
+func randomSuffix() string {
+	b := make([]byte, 3)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%06x", time.Now().UnixNano()%0xffffff)
+	}
+	return hex.EncodeToString(b)
+}
+
+func scheduleDir(remotePath, id string) string {
+	return path.Join(remotePath, stateDir, "schedules", id)
+}
+
+// scheduleScript renders the shell script cron executes for a schedule. It
+// refreshes a dedicated checkout of the requested ref, rebuilds the image and
+// runs the command, appending one tab-separated line to the history file.
+func scheduleScript(dir string, s Schedule) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "#!/bin/sh\n# Generated by osiris-lite for schedule %s. Do not edit.\n", s.ID)
+	b.WriteString("PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin\n")
+	fmt.Fprintf(&b, "dir=%s\n", shellQuote(dir))
+	fmt.Fprintf(&b, "ref=%s\n", shellQuote(s.Ref))
+	fmt.Fprintf(&b, "name=%s-$(date +%%s)\n", shellQuote(s.Container))
+	b.WriteString(`src="$dir/src"
+start=$(date -u +%Y-%m-%dT%H:%M:%SZ)
+mkdir -p "$dir/runs"
+log="$dir/runs/$start.log"
+rm -f "$dir/commit"
+(
+  set -e
+`)
+	fmt.Fprintf(&b, "  [ -d \"$src/.git\" ] || git clone --quiet %s \"$src\"\n", shellQuote(s.Repo))
+	b.WriteString(`  cd "$src"
+  git fetch --quiet --prune --tags origin
+  commit=$(git rev-parse --verify -q "origin/$ref^{commit}" || git rev-parse --verify "$ref^{commit}")
+  git checkout --quiet --force --detach "$commit"
+  git submodule update --quiet --init --recursive
+  echo "$commit" > "$dir/commit"
+`)
+	fmt.Fprintf(&b, "  docker build -t %s -f %s .\n", shellQuote(s.Image), shellQuote(s.Dockerfile))
+	if d, err := time.ParseDuration(s.Duration); err == nil && d > 0 {
+		// Watchdog stops the container gracefully once the duration elapses
+		fmt.Fprintf(&b, "  ( sleep %d; docker stop --timeout -1 \"$name\" >/dev/null 2>&1 ) &\n", int(d.Seconds()))
+		b.WriteString("  watchdog=$!\n")
+	}
+	b.WriteString("  set +e\n")
+	opts := ""
+	if extra := s.Docker.args(); extra != "" {
+		opts = extra + " "
+	}
+	fmt.Fprintf(&b, "  docker run --rm -v \"$src:/app\" -w /app --name \"$name\" %s%s bash -c %s\n",
+		opts, shellQuote(s.Image), shellQuote(s.Command))
+	b.WriteString(`  rc=$?
+  [ -n "${watchdog:-}" ] && kill "$watchdog" 2>/dev/null
+  exit $rc
+) > "$log" 2>&1
+code=$?
+commit=$(cat "$dir/commit" 2>/dev/null || echo "-")
+printf '%s\t%s\t%s\t%s\t%s\n' "$start" "$(date -u +%Y-%m-%dT%H:%M:%SZ)" "$code" "$commit" "$log" >> "$dir/history"
+`)
+	return b.String()
+}
+
+// cronMarker ends the crontab line of schedule id, so it can be found again.
+func cronMarker(id string) string {
+	return " # osiris:" + id
+}
+
+// cronMarkerPattern matches the crontab line of schedule id only, not those
+// of schedules whose IDs start with it.
+func cronMarkerPattern(id string) string {
+	return regexp.QuoteMeta(cronMarker(id)) + "$"
+}
+
+func (s *SSHClient) AddSchedule(remotePath string, sched Schedule) error {
+	dir := scheduleDir(remotePath, sched.ID)
+
+	if _, err := s.RunCommand(fmt.Sprintf("test ! -e %s", shellQuote(dir))); err != nil {
+		return fmt.Errorf("schedule %s already exists", sched.ID)
+	}
+	if _, err := s.RunCommand(fmt.Sprintf("mkdir -p %s", shellQuote(dir))); err != nil {
+		return fmt.Errorf("failed to create schedule directory: %w", err)
+	}
+
+	meta, err := json.Marshal(sched)
+	if err != nil {
+		return err
+	}
+	if _, err := s.RunCommandWithInput(fmt.Sprintf("cat > %s", shellQuote(path.Join(dir, "schedule.json"))), strings.NewReader(string(meta)+"\n")); err != nil {
+		return fmt.Errorf("failed to write schedule: %w", err)
+	}
+
+	script := path.Join(dir, "run.sh")
+	if _, err := s.RunCommandWithInput(fmt.Sprintf("cat > %s && chmod +x %s", shellQuote(script), shellQuote(script)), strings.NewReader(scheduleScript(dir, sched))); err != nil {
+		return fmt.Errorf("failed to write schedule script: %w", err)
+	}
+
+	// flock keeps a slow run from overlapping with the next trigger. Cron
+	// reads an unescaped % in the command as a newline.
+	command := fmt.Sprintf("flock -n %s %s", shellQuote(path.Join(dir, "lock")), shellQuote(script))
+	line := fmt.Sprintf("%s %s%s", sched.Cron, strings.ReplaceAll(command, "%", `\%`), cronMarker(sched.ID))
+	cronCmd := fmt.Sprintf(`(crontab -l 2>/dev/null | grep -v -E %s; echo %s) | crontab -`,
+		shellQuote(cronMarkerPattern(sched.ID)), shellQuote(line))
+	if output, err := s.RunCommand(cronCmd); err != nil {
+		return fmt.Errorf("failed to install crontab entry: %w\n%s", err, output)
+	}
+	return nil
+}
+
+func (s *SSHClient) ListSchedules(remotePath string) ([]Schedule, map[string]ScheduleRun, error) {
+	root := path.Join(remotePath, stateDir, "schedules")
+	listCmd := fmt.Sprintf(`for d in %s/*/; do [ -f "$d/schedule.json" ] || continue; cat "$d/schedule.json"; tail -n 1 "$d/history" 2>/dev/null; echo; done`, shellQuote(root))
+	output, err := s.RunCommand(listCmd)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list schedules: %w", err)
+	}
+
+	var schedules []Schedule
+	lastRuns := make(map[string]ScheduleRun)
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "{") {
+			var sched Schedule
+			if err := json.Unmarshal([]byte(line), &sched); err != nil {
+				return nil, nil, fmt.Errorf("failed to parse schedule: %w", err)
+			}
+			schedules = append(schedules, sched)
+			continue
+		}
+		if len(schedules) > 0 {
+			lastRuns[schedules[len(schedules)-1].ID] = parseScheduleRun(line)
+		}
+	}
+	return schedules, lastRuns, nil
+}
+
+func (s *SSHClient) RemoveSchedule(remotePath, id string) error {
+	if !scheduleIDPattern.MatchString(id) {
+		return fmt.Errorf("invalid schedule ID %q", id)
+	}
+	dir := scheduleDir(remotePath, id)
+
+	if _, err := s.RunCommand(fmt.Sprintf("test -d %s", shellQuote(dir))); err != nil {
+		return fmt.Errorf("schedule %s does not exist", id)
+	}
+
+	cronCmd := fmt.Sprintf(`crontab -l 2>/dev/null | grep -v -E %s | crontab -`, shellQuote(cronMarkerPattern(id)))
+	if output, err := s.RunCommand(cronCmd); err != nil {
+		return fmt.Errorf("failed to remove crontab entry: %w\n%s", err, output)
+	}
+
+	// Drops the checkout, script and run logs together
+	if _, err := s.RunCommand(fmt.Sprintf("rm -rf %s", shellQuote(dir))); err != nil {
+		return fmt.Errorf("failed to remove schedule directory: %w", err)
+	}
+	return nil
+}
+
+func (s *SSHClient) ScheduleHistory(remotePath, id string, limit int) ([]ScheduleRun, error) {
+	if !scheduleIDPattern.MatchString(id) {
+		return nil, fmt.Errorf("invalid schedule ID %q", id)
+	}
+	dir := scheduleDir(remotePath, id)
+
+	if _, err := s.RunCommand(fmt.Sprintf("test -d %s", shellQuote(dir))); err != nil {
+		return nil, fmt.Errorf("schedule %s does not exist", id)
+	}
+
+	output, err := s.RunCommand(fmt.Sprintf("tail -n %d %s 2>/dev/null || true", limit, shellQuote(path.Join(dir, "history"))))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+
+	var runs []ScheduleRun
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		if line != "" {
+			runs = append(runs, parseScheduleRun(line))
+		}
+	}
+	return runs, nil
+}
+
+func parseScheduleRun(line string) ScheduleRun {
+	fields := strings.Split(line, "\t")
+	for len(fields) < 5 {
+		fields = append(fields, "-")
+	}
+	return ScheduleRun{Start: fields[0], End: fields[1], ExitCode: fields[2], Commit: fields[3], Log: fields[4]}
+}
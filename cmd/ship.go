@@ -0,0 +1,324 @@
+package cmd
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// imageArchive is an image built by the local Docker daemon and saved once,
+// then shipped to any number of hosts. Building and saving happen on first
+// use, so hosts that already have the image cost nothing.
+type imageArchive struct {
+	ref  string
+	opts BuildOptions
+
+	once sync.Once
+	path string
+	err  error
+}
+
+func (a *imageArchive) prepare() (string, error) {
+	a.once.Do(func() {
+		if a.err = buildLocalImage(a.ref, a.opts); a.err != nil {
+			return
+		}
+
+		f, err := os.CreateTemp("", "osiris-image-*.tar")
+		if err != nil {
+			a.err = fmt.Errorf("failed to create image archive: %w", err)
+			return
+		}
+		f.Close()
+		a.path = f.Name()
+
+		fmt.Printf("Saving %s...\n", a.ref)
+		save := exec.Command("docker", "save", "-o", a.path, a.ref)
+		save.Stderr = os.Stderr
+		if err := save.Run(); err != nil {
+			a.err = fmt.Errorf("failed to save image: %w", err)
+		}
+	})
+	return a.path, a.err
+}
+
+func (a *imageArchive) cleanup() {
+	if a.path != "" {
+		os.Remove(a.path)
+	}
+}
+
+// buildLocalImage builds ref with the local Docker daemon. Secrets are passed
+// through the environment exactly as on the remote.
+func buildLocalImage(ref string, opts BuildOptions) error {
+	fmt.Printf("Building Docker image %s locally...\n", ref)
+	args := append([]string{"build", "--progress=plain", "-t", ref, "-f", dockerfilePath}, opts.argv()...)
+	build := exec.Command("docker", append(args, ".")...)
+	build.Env = append(os.Environ(), "DOCKER_BUILDKIT=1")
+	for name, value := range opts.secretEnv.values {
+		build.Env = append(build.Env, name+"="+value)
+	}
+	build.Stdout = os.Stdout
+	build.Stderr = os.Stderr
+	if err := build.Run(); err != nil {
+		return fmt.Errorf("failed to build Docker image locally: %w", err)
+	}
+	return nil
+}
+
+// localPlatform returns the os/arch of the local Docker daemon.
+func localPlatform() (string, error) {
+	output, err := exec.Command("docker", "version", "--format", "{{.Server.Os}}/{{.Server.Arch}}").Output()
+	if err != nil {
+		return "", fmt.Errorf("local Docker daemon is not available: %w", err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// shipOnHost loads the locally built image into h's Docker daemon unless it is
+// already there, and returns its image ID.
+func (s *SSHClient) shipOnHost(h Host, archive *imageArchive, out io.Writer) (string, error) {
+	ref := archive.ref
+	if !rebuild {
+		if _, err := s.RunCommand(fmt.Sprintf("docker image inspect %s >/dev/null 2>&1", shellQuote(ref))); err == nil {
+			fmt.Fprintf(out, "✓ Image %s is already present, skipping\n", ref)
+			return s.ImageID(ref)
+		}
+	}
+
+	// An image built for another architecture would load but not run
+	if archive.opts.Platform == "" {
+		local, err := localPlatform()
+		if err != nil {
+			return "", err
+		}
+		remote, err := s.RunCommand("docker version --format '{{.Server.Os}}/{{.Server.Arch}}'")
+		if err != nil {
+			return "", fmt.Errorf("failed to read remote platform: %w", err)
+		}
+		if remote = strings.TrimSpace(remote); remote != local {
+			return "", fmt.Errorf("local Docker builds %s but %s runs %s; pass --platform %s", local, h.Name, remote, remote)
+		}
+	}
+
+	path, err := archive.prepare()
+	if err != nil {
+		return "", err
+	}
+
+	have, err := s.remoteChainIDs()
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.ShipImage(path, have, out); err != nil {
+		return "", err
+	}
+
+	id, err := s.ImageID(ref)
+	if err != nil {
+		return "", err
+	}
+	record := ImageRecord{Ref: ref, ID: id, Build: archive.opts, Host: s.alias, Built: time.Now().UTC(), Local: true}
+	return id, s.recordImage(h.RemotePath, record)
+}
+
+// remoteChainIDs returns the layer chain IDs the remote daemon already has.
+// It returns nil when the remote uses the containerd image store, whose
+// `docker load` needs every layer in the archive.
+func (s *SSHClient) remoteChainIDs() (map[string]bool, error) {
+	driver, err := s.RunCommand("docker info --format '{{json .DriverStatus}}'")
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect remote Docker: %w", err)
+	}
+	if strings.Contains(driver, "io.containerd.snapshotter") {
+		return nil, nil
+	}
+
+	output, err := s.RunCommand(`ids=$(docker images -aq --no-trunc | sort -u); [ -z "$ids" ] || docker image inspect --format '{{json .RootFS.Layers}}' $ids`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list remote layers: %w", err)
+	}
+
+	have := make(map[string]bool)
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		var diffIDs []string
+		if json.Unmarshal([]byte(line), &diffIDs) != nil {
+			continue
+		}
+		for _, id := range chainIDs(diffIDs) {
+			have[id] = true
+		}
+	}
+	return have, nil
+}
+
+// chainIDs returns the chain ID of every layer prefix, which is how Docker
+// identifies a layer together with everything below it.
+func chainIDs(diffIDs []string) []string {
+	var ids []string
+	chain := ""
+	for _, diffID := range diffIDs {
+		if chain == "" {
+			chain = diffID
+		} else {
+			sum := sha256.Sum256([]byte(chain + " " + diffID))
+			chain = "sha256:" + hex.EncodeToString(sum[:])
+		}
+		ids = append(ids, chain)
+	}
+	return ids
+}
+
+// ShipImage streams a `docker save` archive through zstd into `docker load` on
+// the remote, leaving out layers whose chain ID the remote already has; load
+// never reads those.
+func (s *SSHClient) ShipImage(archive string, have map[string]bool, out io.Writer) error {
+	skip, total, err := layersToSkip(archive, have)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Shipping image: %d of %d layers already on the remote\n", len(skip), total)
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(filterArchive(archive, skip, pw))
+	}()
+
+	compress := exec.Command("zstd", "-q", "-c", "-T0")
+	compress.Stdin = pr
+	compressed, err := compress.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	compress.Stderr = out
+	if err := compress.Start(); err != nil {
+		return fmt.Errorf("failed to start zstd (is it installed locally?): %w", err)
+	}
+
+	counter := &countingReader{r: compressed}
+	start := time.Now()
+	loadErr := s.RunCommandWithOutput("zstd -q -d -c | docker load", counter, out)
+	if loadErr != nil {
+		// Nothing reads zstd's output any more
+		compress.Process.Kill()
+	}
+	pr.CloseWithError(io.ErrClosedPipe)
+	if err := compress.Wait(); err != nil && loadErr == nil {
+		return fmt.Errorf("failed to compress image: %w", err)
+	}
+	if loadErr != nil {
+		return fmt.Errorf("failed to load image on the remote (is zstd installed there?): %w", loadErr)
+	}
+
+	fmt.Fprintf(out, "Shipped %s in %s\n", formatKB(counter.n/1024), time.Since(start).Round(time.Second))
+	return nil
+}
+
+// layersToSkip reads the archive's manifest and config and returns the layer
+// files the remote does not need along with the total layer count.
+func layersToSkip(archive string, have map[string]bool) (map[string]bool, int, error) {
+	f, err := os.Open(archive)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+
+	var manifest []struct {
+		Config string
+		Layers []string
+	}
+	files := make(map[string][]byte)
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to read image archive: %w", err)
+		}
+		// Only small JSON entries are kept; layers are skipped over
+		if hdr.Typeflag == tar.TypeReg && hdr.Size < 1<<20 {
+			data, err := io.ReadAll(tr)
+			if err != nil {
+				return nil, 0, fmt.Errorf("failed to read image archive: %w", err)
+			}
+			files[hdr.Name] = data
+		}
+	}
+
+	if err := json.Unmarshal(files["manifest.json"], &manifest); err != nil || len(manifest) != 1 {
+		return nil, 0, fmt.Errorf("unexpected image archive layout")
+	}
+	var config struct {
+		RootFS struct {
+			DiffIDs []string `json:"diff_ids"`
+		} `json:"rootfs"`
+	}
+	if err := json.Unmarshal(files[manifest[0].Config], &config); err != nil {
+		return nil, 0, fmt.Errorf("failed to read image config: %w", err)
+	}
+	layers := manifest[0].Layers
+	if len(config.RootFS.DiffIDs) != len(layers) {
+		return nil, 0, fmt.Errorf("image config and manifest disagree on layers")
+	}
+
+	skip := make(map[string]bool)
+	for i, id := range chainIDs(config.RootFS.DiffIDs) {
+		if have[id] {
+			skip[layers[i]] = true
+		}
+	}
+	return skip, len(layers), nil
+}
+
+// filterArchive copies the archive to w without the skipped entries.
+func filterArchive(archive string, skip map[string]bool, w io.Writer) error {
+	f, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	tr := tar.NewReader(f)
+	tw := tar.NewWriter(w)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if skip[hdr.Name] {
+			continue
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := io.Copy(tw, tr); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
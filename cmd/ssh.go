@@ -1,10 +1,13 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
 	"net"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
 	"strings"
 	"time"
@@ -13,8 +16,12 @@ import (
 	"golang.org/x/crypto/ssh"
 )
 
+// syncExcludes are the paths never synced to the remote.
+var syncExcludes = []string{".git", "out", "cache", "osiris-lite", stateDir}
+
 type SSHClient struct {
 	client *ssh.Client
+	alias  string
 }
 
 func expandPath(path string) string {
@@ -25,6 +32,11 @@ func expandPath(path string) string {
 	return path
 }
 
+// shellQuote quotes s for safe interpolation into a remote sh command line.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
 func loadSSHKey(keyPath string) (ssh.AuthMethod, error) {
 	key, err := os.ReadFile(expandPath(keyPath))
 	if err != nil {
@@ -115,7 +127,7 @@ func NewSSHClientWithPassword(hostAlias, pwd string) (*SSHClient, error) {
 		}
 	}
 
-	return &SSHClient{client: client}, nil
+	return &SSHClient{client: client, alias: hostAlias}, nil
 }
 
 func connectWithProxy(targetAlias, proxyAlias string, targetConfig *ssh.ClientConfig, targetPassword string) (*ssh.Client, error) {
@@ -197,7 +209,37 @@ func (s *SSHClient) RunCommand(command string) (string, error) {
 	return string(output), nil
 }
 
+func (s *SSHClient) RunCommandWithInput(command string, input io.Reader) (string, error) {
+	session, err := s.client.NewSession()
+	if err != nil {
+		return "", fmt.Errorf("failed to create session: %w", err)
+	}
+	defer session.Close()
+
+	// Feed input to the remote command's stdin
+	session.Stdin = input
+
+	output, err := session.CombinedOutput(command)
+	if err != nil {
+		return string(output), fmt.Errorf("command failed: %w", err)
+	}
+	return string(output), nil
+}
+
 func (s *SSHClient) RunCommandWithLiveOutput(command string) error {
+	return s.RunCommandWithLiveOutputAndInput(command, nil)
+}
+
+func (s *SSHClient) RunCommandWithLiveOutputAndInput(command string, input io.Reader) error {
+	return s.runStreaming(command, input, os.Stdout, os.Stderr)
+}
+
+// RunCommandWithOutput streams the command's stdout and stderr to out.
+func (s *SSHClient) RunCommandWithOutput(command string, input io.Reader, out io.Writer) error {
+	return s.runStreaming(command, input, out, out)
+}
+
+func (s *SSHClient) runStreaming(command string, input io.Reader, stdout, stderr io.Writer) error {
 	session, err := s.client.NewSession()
 	if err != nil {
 		return fmt.Errorf("failed to create session: %w", err)
@@ -205,8 +247,9 @@ func (s *SSHClient) RunCommandWithLiveOutput(command string) error {
 	defer session.Close()
 
 	// Set up pipes to stream output in real-time
-	session.Stdout = os.Stdout
-	session.Stderr = os.Stderr
+	session.Stdout = stdout
+	session.Stderr = stderr
+	session.Stdin = input
 
 	if err := session.Run(command); err != nil {
 		return fmt.Errorf("command failed: %w", err)
@@ -214,12 +257,12 @@ func (s *SSHClient) RunCommandWithLiveOutput(command string) error {
 	return nil
 }
 
-func (s *SSHClient) GetStatus(image string) error {
+func (s *SSHClient) GetStatus(remotePath string) error {
 	fmt.Println("📋 Checking status on remote server...")
 
 	// Check Docker containers
 	fmt.Println("┌─ Docker Containers")
-	containersCmd := fmt.Sprintf(`docker ps --filter "ancestor=%s" --format "{{.ID}} {{.Names}} {{.Status}} ({{.RunningFor}})" 2>/dev/null || true`, image)
+	containersCmd := `docker ps --filter label=osiris.job --format "{{.ID}} {{.Names}} {{.Status}} ({{.RunningFor}})" 2>/dev/null || true`
 	containers, err := s.RunCommand(containersCmd)
 	if err != nil {
 		return fmt.Errorf("failed to check containers: %w", err)
@@ -236,6 +279,48 @@ func (s *SSHClient) GetStatus(image string) error {
 	}
 	fmt.Println("│")
 
+	// Summarize job sets (matrix runs) as one line each
+	fmt.Println("├─ Job Sets")
+	jobs, err := s.ListJobs(remotePath)
+	if err != nil {
+		return err
+	}
+	sets := summarizeJobSets(jobs)
+	if len(sets) == 0 {
+		fmt.Println("│  No job sets")
+	} else {
+		for _, set := range sets {
+			fmt.Printf("│  %s\n", set)
+		}
+	}
+	fmt.Println("│")
+
+	// Each distributed campaign is one unit, whatever its instance count
+	if campaigns := s.summarizeCampaigns(remotePath, jobs); len(campaigns) > 0 {
+		fmt.Println("├─ Campaigns")
+		for _, campaign := range campaigns {
+			fmt.Printf("│  %s\n", campaign)
+		}
+		fmt.Println("│")
+	}
+
+	// Running jobs and the last few finished ones, with their results so far
+	fmt.Println("├─ Jobs")
+	recent := recentJobs(jobs, 5)
+	if len(recent) == 0 {
+		fmt.Println("│  No jobs")
+	} else {
+		summaries := s.resultSummaries(remotePath, recent)
+		for _, j := range recent {
+			line := fmt.Sprintf("%s: %s", j.ID, j.describeStatus())
+			if r, ok := summaries[j.ID]; ok {
+				line += ", " + r.describe()
+			}
+			fmt.Printf("│  %s\n", line)
+		}
+	}
+	fmt.Println("│")
+
 	// Check fuzzer processes
 	fmt.Println("├─ Fuzzer Processes")
 	processesCmd := `pgrep -a -i fuzzer || true`
@@ -290,18 +375,27 @@ func (s *SSHClient) GetStatus(image string) error {
 	return nil
 }
 
-func (s *SSHClient) KillAll(image string) error {
+func (s *SSHClient) KillAll(remotePath string) error {
 	fmt.Println("Killing all jobs on remote server...")
 
-	// Stop and remove Docker containers
+	// Stop job set runners first so queued jobs don't start as containers stop
+	fmt.Println("Cancelling queued jobs...")
+	state := path.Join(remotePath, stateDir)
+	cancelCmd := fmt.Sprintf(`for p in %s/sets/*/pid; do [ -f "$p" ] && kill "$(cat "$p")" 2>/dev/null && rm -f "$p"; done; for d in %s/jobs/*/; do grep -qx queued "$d/status" 2>/dev/null && touch "$d/cancelled" && echo cancelled > "$d/status"; done; true`,
+		shellQuote(state), shellQuote(state))
+	if _, err := s.RunCommand(cancelCmd); err != nil {
+		return fmt.Errorf("failed to cancel queued jobs: %w", err)
+	}
+
+	// Stop and remove job containers, whichever context image they run
 	fmt.Println("Stopping Docker containers...")
-	stopCmd := fmt.Sprintf(`docker ps --filter "ancestor=%s" -q | xargs -r docker stop --timeout -1 || true`, image)
+	stopCmd := `docker ps --filter label=osiris.job -q | xargs -r docker stop --timeout -1 || true`
 	_, err := s.RunCommand(stopCmd)
 	if err != nil {
 		return fmt.Errorf("failed to stop containers: %w", err)
 	}
 
-	rmCmd := fmt.Sprintf(`docker ps -a --filter "ancestor=%s" -q | xargs -r docker rm || true`, image)
+	rmCmd := `docker ps -a --filter label=osiris.job -q | xargs -r docker rm || true`
 	_, err = s.RunCommand(rmCmd)
 	if err != nil {
 		return fmt.Errorf("failed to remove containers: %w", err)
@@ -311,7 +405,16 @@ func (s *SSHClient) KillAll(image string) error {
 	return nil
 }
 
-func (s *SSHClient) KillContainer(containerID string) error {
+func (s *SSHClient) KillContainer(remotePath, containerID string) error {
+	// A queued job has no container yet; cancel it before it starts
+	dir := jobDir(remotePath, containerID)
+	cancelCmd := fmt.Sprintf(`grep -qx queued %s/status 2>/dev/null && touch %s/cancelled && echo cancelled > %s/status`,
+		shellQuote(dir), shellQuote(dir), shellQuote(dir))
+	if _, err := s.RunCommand(cancelCmd); err == nil {
+		fmt.Printf("Cancelled queued job: %s\n", containerID)
+		return nil
+	}
+
 	// Stop container gracefully, then remove it (same flow as KillAll)
 	fmt.Printf("Stopping container %s gracefully...\n", containerID)
 	stopCmd := fmt.Sprintf("docker stop --timeout -1 %s", containerID)
@@ -329,28 +432,66 @@ func (s *SSHClient) KillContainer(containerID string) error {
 	return nil
 }
 
-func (s *SSHClient) RunRemoteCommand(remotePath, image, container, command string) error {
+// BuildImage makes sure ref exists on the remote, building it from the synced
+// context unless it is already there, and returns its image ID.
+func (s *SSHClient) BuildImage(remotePath, ref string, opts BuildOptions, force bool, out io.Writer) (string, error) {
+	if !force {
+		if _, err := s.RunCommand(fmt.Sprintf("docker image inspect %s >/dev/null 2>&1", shellQuote(ref))); err == nil {
+			fmt.Fprintf(out, "✓ Image %s is up to date, skipping build (use --rebuild to force)\n", ref)
+			return s.ImageID(ref)
+		}
+	}
+
+	fmt.Fprintf(out, "Building Docker image %s...\n", ref)
+	buildCmd := fmt.Sprintf(`cd %s && DOCKER_BUILDKIT=1 docker build --progress=plain -t %s -f %s`,
+		remotePath, shellQuote(ref), shellQuote(dockerfilePath))
+	if extra := opts.args(); extra != "" {
+		buildCmd += " " + extra
+	}
+	buildCmd += " ."
+
+	// Build secrets reach BuildKit over stdin, never via the remote disk
+	if err := s.RunCommandWithOutput(withEnv(buildCmd), strings.NewReader(opts.secretEnv.exports()), out); err != nil {
+		return "", fmt.Errorf("failed to build Docker image: %w", err)
+	}
+	fmt.Fprintf(out, "Docker build completed successfully\n")
+
+	id, err := s.ImageID(ref)
+	if err != nil {
+		return "", err
+	}
+	record := ImageRecord{Ref: ref, ID: id, Build: opts, Host: s.alias, Built: time.Now().UTC()}
+	return id, s.recordImage(remotePath, record)
+}
+
+// ImageID returns the ID (config digest) of an image on the remote.
+func (s *SSHClient) ImageID(ref string) (string, error) {
+	output, err := s.RunCommand(fmt.Sprintf("docker image inspect --format '{{.Id}}' %s", shellQuote(ref)))
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect image %s: %w", ref, err)
+	}
+	return strings.TrimSpace(output), nil
+}
+
+func (s *SSHClient) RunRemoteCommand(remotePath string, job Job, env jobEnv) error {
 	fmt.Println("Connected to remote server...")
 
-	// Build Docker image
-	fmt.Println("Building Docker image...")
-	buildCmd := fmt.Sprintf(`cd %s && docker build -t "%s" -f %s .`, remotePath, image, dockerfilePath)
-	output, err := s.RunCommand(buildCmd)
+	id, err := s.BuildImage(remotePath, job.Image, job.Build, rebuild, os.Stdout)
 	if err != nil {
-		fmt.Printf("Docker build output:\n%s\n", output)
-		return fmt.Errorf("failed to build Docker image: %w", err)
+		return err
 	}
-	fmt.Printf("Docker build completed successfully\n")
+	job.ImageID = id
 
-	// Run command in Docker with live output
+	// Record the run as a job, then run its script with live output
 	fmt.Println("Running command...")
-	suffix := time.Now().UnixNano() % 10000 // Just last 4 digits
-	containerName := fmt.Sprintf("%s-%d", container, suffix)
-	dockerCmd := fmt.Sprintf(`cd %s && docker run --rm -v "%s:/app" -w /app --name "%s" "%s" bash -c "%s"`,
-		remotePath, remotePath, containerName, image, command)
+	if err := s.CreateJob(remotePath, job); err != nil {
+		return err
+	}
+	fmt.Printf("Job: %s\n", job.ID)
 
-	// Use live output streaming for the Docker run command
-	err = s.RunCommandWithLiveOutput(dockerCmd)
+	// The environment reaches the job over stdin, never via the remote disk
+	script := path.Join(jobDir(remotePath, job.ID), "run.sh")
+	err = s.RunCommandWithLiveOutputAndInput(withEnv("exec sh "+shellQuote(script)), strings.NewReader(env.exports()))
 	if err != nil {
 		return fmt.Errorf("failed to run command: %w", err)
 	}
@@ -359,48 +500,142 @@ func (s *SSHClient) RunRemoteCommand(remotePath, image, container, command strin
 	return nil
 }
 
-func (s *SSHClient) PullResults(remoteRootPath, resultsPath string) error {
-	// Create the corpus path on remote
-	remoteResultsPath := filepath.Join(remoteRootPath, resultsPath)
+// PullResults fetches the shared corpus directory rel, the one place results
+// from every job end up. It reports false when rel is not a shared corpus, as
+// jobs then write their results to their own workspaces.
+func (s *SSHClient) PullResults(remoteRootPath, rel, localPath string, include, exclude []string) (bool, error) {
+	shared := sharedCorpusDir(remoteRootPath, rel)
+	if _, err := s.RunCommand(fmt.Sprintf("test -d %s", shellQuote(shared))); err != nil {
+		return false, nil
+	}
 
-	fmt.Println("Pulling results from remote server...")
+	fmt.Printf("Pulling results to: %s\n", localPath)
+	return true, s.pullDir(shared, localPath, include, exclude)
+}
 
-	// Use rsync to pull the files
-	cmd := exec.Command("rsync", "-avz",
-		"-e", "ssh -F "+os.Getenv("HOME")+"/.ssh/config",
-		remote+":"+remoteResultsPath+"/", resultsPath+"/")
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+// latestJob returns the ID of the most recently created job that has run,
+// or "" when there is none.
+func (s *SSHClient) latestJob(remotePath string) (string, error) {
+	jobs, err := s.ListJobs(remotePath)
+	if err != nil {
+		return "", err
+	}
+	for i := len(jobs) - 1; i >= 0; i-- {
+		if jobs[i].Status != "queued" && jobs[i].Status != "cancelled" {
+			return jobs[i].ID, nil
+		}
+	}
+	return "", nil
+}
 
-	return cmd.Run()
+// pulledDir is a directory PullJob fetched: Source on the remote, into Rel
+// under the local job directory.
+type pulledDir struct {
+	Rel, Source string
+	// Corpus marks the corpus directory of the job's fuzzer adapter
+	Corpus bool
 }
 
-func (s *SSHClient) SyncFiles(localPath, remotePath string) error {
-	// For now, fall back to external rsync but with better password handling
-	// We'll use SSH config which should work with our established connection
+// PullJob fetches a job's record and log, then the results directory rel from
+// its workspace, or from the shared corpus it used, into localPath. It also
+// fetches the corpus and results directories of the job's fuzzer adapter,
+// e.g. Medusa's corpus with its coverage report. It returns the directories
+// the job had.
+func (s *SSHClient) PullJob(remotePath, jobID, rel, localPath string, include, exclude []string) ([]pulledDir, error) {
+	// Job metadata, script and output log live under the state directory
+	if err := s.pullDir(jobDir(remotePath, jobID), localPath, nil, []string{"/workspace"}); err != nil {
+		return nil, err
+	}
 
-	// Create the remote directory first via SSH
-	_, err := s.RunCommand(fmt.Sprintf("mkdir -p %s", remotePath))
+	data, err := os.ReadFile(filepath.Join(localPath, "job.json"))
 	if err != nil {
-		return fmt.Errorf("failed to create remote directory: %w", err)
+		return nil, fmt.Errorf("failed to read job %s: %w", jobID, err)
+	}
+	var job Job
+	if err := json.Unmarshal(data, &job); err != nil {
+		return nil, fmt.Errorf("failed to parse job metadata: %w", err)
+	}
+
+	dirs := []string{rel}
+	if job.Fuzzer != nil {
+		for _, dir := range []string{job.Fuzzer.Corpus, job.Fuzzer.Results} {
+			if dir != "" && dir != rel && dir != dirs[len(dirs)-1] {
+				dirs = append(dirs, dir)
+			}
+		}
+	}
+	var pulled []pulledDir
+	for _, dir := range dirs {
+		source := path.Join(job.Workspace, dir)
+		for _, shared := range job.SharedCorpus {
+			if shared == dir {
+				source = sharedCorpusDir(remotePath, dir)
+			}
+		}
+		if job.Workspace == "" {
+			source = path.Join(remotePath, dir)
+		}
+		if _, err := s.RunCommand(fmt.Sprintf("test -d %s", shellQuote(source))); err != nil {
+			fmt.Printf("Job %s has no %s directory\n", jobID, dir)
+			continue
+		}
+		if err := s.pullDir(source, filepath.Join(localPath, filepath.FromSlash(dir)), include, exclude); err != nil {
+			return nil, err
+		}
+		pulled = append(pulled, pulledDir{Rel: dir, Source: source, Corpus: job.Fuzzer != nil && dir == job.Fuzzer.Corpus})
+	}
+
+	// Only a complete pull frees the workspace for pruning
+	if len(include) == 0 && len(exclude) == 0 {
+		if _, err := s.RunCommand(fmt.Sprintf("date -u +%%Y-%%m-%%dT%%H:%%M:%%SZ > %s", shellQuote(path.Join(jobDir(remotePath, jobID), "pulled")))); err != nil {
+			return nil, fmt.Errorf("failed to mark job %s as pulled: %w", jobID, err)
+		}
+	}
+	return pulled, nil
+}
+
+// pullDir copies the remote directory src into dst, selecting files with
+// --include and --exclude globs. It uses rsync, or a compressed stream over
+// the SSH session with --stream.
+func (s *SSHClient) pullDir(src, dst string, include, exclude []string) error {
+	if pullStream {
+		return s.streamDir(src, dst, include, exclude)
 	}
 
-	// Use rsync but let it use our established SSH config
-	cmd := exec.Command("rsync", "-avz", "--delete",
-		"--exclude=.git", "--exclude=out", "--exclude=cache", "--exclude=osiris-lite",
-		"-e", "ssh -F "+os.Getenv("HOME")+"/.ssh/config",
-		localPath, remote+":"+remotePath)
+	args := []string{"-az", "--prune-empty-dirs"}
+	for _, rule := range pullFilters(include, exclude) {
+		args = append(args, "--filter="+rule)
+	}
+	args = append(args, "-e", "ssh -F "+os.Getenv("HOME")+"/.ssh/config", s.alias+":"+src+"/", dst+"/")
+	cmd := exec.Command("rsync", args...)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to pull %s: %w", src, err)
+	}
+	return nil
+}
+
+func (s *SSHClient) SyncFiles(localPath, remotePath string, out io.Writer, interactive bool) error {
+	// Create the remote directory first via SSH
+	_, err := s.RunCommand(fmt.Sprintf("mkdir -p %s", remotePath))
+	if err != nil {
+		return fmt.Errorf("failed to create remote directory: %w", err)
+	}
 
-	return cmd.Run()
+	// rsync runs externally and reuses our SSH config
+	opts, err := loadSyncOptions()
+	if err != nil {
+		return err
+	}
+	return pushTree(strings.TrimSuffix(localPath, "/"), s.alias+":"+remotePath, opts, out, interactive)
 }
 
-func (s *SSHClient) ConnectToLogs(image, containerID string) error {
+func (s *SSHClient) ConnectToLogs(containerID string) error {
 	// If no container ID provided, find a running container
 	if containerID == "" {
 		fmt.Println("🔍 Finding running container...")
-		containersCmd := fmt.Sprintf(`docker ps --filter "ancestor=%s" --format "{{.ID}}" | head -1`, image)
+		containersCmd := `docker ps --filter label=osiris.job --format "{{.ID}}" | head -1`
 		output, err := s.RunCommand(containersCmd)
 		if err != nil {
 			return fmt.Errorf("failed to find containers: %w", err)
@@ -408,7 +643,7 @@ func (s *SSHClient) ConnectToLogs(image, containerID string) error {
 
 		containerID = strings.TrimSpace(output)
 		if containerID == "" {
-			return fmt.Errorf("no running containers found for image %s", image)
+			return fmt.Errorf("no running job containers found")
 		}
 		fmt.Printf("📺 Connecting to container: %s\n", containerID)
 	}
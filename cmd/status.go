@@ -2,26 +2,148 @@ package cmd
 
 import (
 	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/spf13/cobra"
 )
 
-func statusCommand(cmd *cobra.Command, args []string) error {
-	fmt.Println("Checking status...")
+var (
+	statusAll     bool
+	statusTimeout time.Duration
+)
 
-	var client *SSHClient
-	var err error
+// fleetStatus is what status --all gathers from each host.
+type fleetStatus struct {
+	Stats   HostStats
+	Jobs    []JobState
+	Results map[string]*Results
+}
 
-	if password != "" {
-		client, err = NewSSHClientWithPassword(remote, password)
-	} else {
-		client, err = NewSSHClient(remote)
+func newStatusCommand() *cobra.Command {
+	statusCmd := &cobra.Command{
+		Use:   "status",
+		Short: "Check active jobs",
+		RunE:  statusCommand,
 	}
+	statusCmd.Flags().BoolVar(&statusAll, "all", false, "Query every host in the hosts config in parallel")
+	statusCmd.Flags().DurationVar(&statusTimeout, "timeout", 15*time.Second, "Per-host timeout for --all")
+	return statusCmd
+}
 
+func statusCommand(cmd *cobra.Command, args []string) error {
+	if statusAll {
+		return fleetStatusCommand()
+	}
+
+	fmt.Println("Checking status...")
+
+	client, err := connect(currentHost())
 	if err != nil {
-		return fmt.Errorf("failed to connect to remote: %w", err)
+		return err
 	}
 	defer client.Close()
 
-	return client.GetStatus(image)
+	return client.GetStatus(remotePath)
+}
+
+func fleetStatusCommand() error {
+	fleet := fleetHosts()
+	fmt.Printf("Checking status on %d hosts...\n\n", len(fleet))
+
+	results := forEachHost(fleet, statusTimeout, func(h Host, client *SSHClient) (fleetStatus, error) {
+		stats, err := client.Probe(h.RemotePath)
+		if err != nil {
+			return fleetStatus{}, err
+		}
+		jobs, err := client.ListJobs(h.RemotePath)
+		if err != nil {
+			return fleetStatus{}, err
+		}
+		var active []JobState
+		for _, j := range jobs {
+			if j.Status == "running" {
+				active = append(active, j)
+			}
+		}
+		return fleetStatus{Stats: stats, Jobs: jobs, Results: client.resultSummaries(h.RemotePath, active)}, nil
+	})
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "HOST\tTAGS\tRUNNING\tQUEUED\tCAPACITY\tLOAD\tMEM AVAIL\tDISK AVAIL\tERROR")
+	for _, r := range results {
+		tags := strings.Join(r.Host.Tags, ",")
+		if tags == "" {
+			tags = "-"
+		}
+		if r.Err != nil {
+			fmt.Fprintf(w, "%s\t%s\t-\t-\t-\t-\t-\t-\t%v\n", r.Host.Name, tags, r.Err)
+			continue
+		}
+		queued := 0
+		for _, j := range r.Value.Jobs {
+			if j.Status == "queued" {
+				queued++
+			}
+		}
+		stats := r.Value.Stats
+		hostCapacity := r.Host.Capacity
+		if hostCapacity == 0 {
+			hostCapacity = stats.Cores
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%.2f/%d\t%s\t%s\t-\n", r.Host.Name, tags, stats.RunningJobs, queued, hostCapacity,
+			stats.Load1, stats.Cores, formatKB(stats.MemAvailKB), formatKB(stats.DiskAvailKB))
+	}
+	w.Flush()
+
+	// Active jobs across the fleet, addressable as host:job
+	fmt.Println()
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "JOB\tSTATUS\tSTARTED\tRESULTS\tCOMMAND")
+	active := 0
+	for _, r := range results {
+		var order []string
+		campaigns := make(map[string][]JobState)
+		for _, j := range r.Value.Jobs {
+			if j.Status != "running" && j.Status != "queued" {
+				continue
+			}
+			active++
+			if isCampaign(j.Set) {
+				if campaigns[j.Set] == nil {
+					order = append(order, j.Set)
+				}
+				campaigns[j.Set] = append(campaigns[j.Set], j)
+				continue
+			}
+			fmt.Fprintf(w, "%s:%s\t%s\t%s\t%s\t%s\n", r.Host.Name, j.ID, j.Status, j.Started, describeResults(r.Value.Results[j.ID]), j.Command)
+		}
+
+		// A distributed campaign shows as one row, with its most broken instance
+		for _, set := range order {
+			instances := campaigns[set]
+			var worst *Results
+			for _, j := range instances {
+				if res := r.Value.Results[j.ID]; res != nil && (worst == nil || res.Broken > worst.Broken) {
+					worst = res
+				}
+			}
+			fmt.Fprintf(w, "%s:%s\t%d instances active\t%s\t%s\t%s\n", r.Host.Name, set, len(instances), instances[0].Started, describeResults(worst), instances[0].Command)
+		}
+	}
+	if active == 0 {
+		fmt.Println("No active jobs")
+		return nil
+	}
+	return w.Flush()
+}
+
+// describeResults summarizes a job's results for a table cell.
+func describeResults(r *Results) string {
+	if r == nil {
+		return "-"
+	}
+	return r.describe()
 }
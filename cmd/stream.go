@@ -0,0 +1,339 @@
+package cmd
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// partSuffix marks a file still being written by a streamed pull. It is
+// renamed into place once complete, so an interrupted pull never leaves a
+// truncated file that a resume would mistake for a finished one.
+const partSuffix = ".osiris-part"
+
+// tarStatusMarker reports a nonzero exit of the remote tar on stderr; the
+// pipeline's own status is that of zstd.
+const tarStatusMarker = "osiris-tar-status"
+
+var pullStream bool
+
+// remoteFile is one regular file of a remote directory listing.
+type remoteFile struct {
+	name  string
+	size  int64
+	mtime int64
+}
+
+// streamDir fetches the remote directory src into dst as a single
+// `tar | zstd` stream over the SSH session. Files already present locally
+// with the same size and modification time are left out, so rerunning an
+// interrupted pull resumes it. Needs GNU tar and zstd on the remote and zstd
+// locally.
+func (s *SSHClient) streamDir(src, dst string, include, exclude []string) error {
+	files, err := s.listRemoteFiles(src)
+	if err != nil {
+		return err
+	}
+
+	var want []remoteFile
+	var total int64
+	present := 0
+	for _, f := range files {
+		if !selected(f.name, include, exclude) {
+			continue
+		}
+		if info, err := os.Stat(filepath.Join(dst, filepath.FromSlash(f.name))); err == nil && info.Mode().IsRegular() && info.Size() == f.size && info.ModTime().Unix() == f.mtime {
+			present++
+			continue
+		}
+		want = append(want, f)
+		total += f.size
+	}
+	if present > 0 {
+		fmt.Printf("%d file(s) already pulled, resuming\n", present)
+	}
+	if len(want) == 0 {
+		fmt.Printf("✓ %s is up to date\n", dst)
+		return nil
+	}
+
+	// The file list goes to tar on stdin, NUL-separated
+	var list bytes.Buffer
+	for _, f := range want {
+		list.WriteString(f.name)
+		list.WriteByte(0)
+	}
+
+	decompress := exec.Command("zstd", "-q", "-d", "-c")
+	compressed, err := decompress.StdinPipe()
+	if err != nil {
+		return err
+	}
+	archive, err := decompress.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	decompress.Stderr = os.Stderr
+	if err := decompress.Start(); err != nil {
+		return fmt.Errorf("failed to start zstd (is it installed locally?): %w", err)
+	}
+
+	var stderr bytes.Buffer
+	remoteErr := make(chan error, 1)
+	go func() {
+		cmd := fmt.Sprintf("cd %s && { tar --null -T - -cf - || echo %s $? >&2; } | zstd -q -c -T0", shellQuote(src), tarStatusMarker)
+		err := s.runStreaming(cmd, &list, compressed, &stderr)
+		compressed.Close()
+		remoteErr <- err
+	}()
+
+	progress := newProgressBar(total, len(want))
+	received, extractErr := extractTar(archive, dst, progress)
+	progress.finish()
+	if extractErr != nil {
+		// Nothing reads zstd's output any more
+		decompress.Process.Kill()
+	}
+	decompress.Wait()
+	tarStatus, messages := splitTarStatus(stderr.String())
+	if err := <-remoteErr; err != nil && extractErr == nil {
+		return fmt.Errorf("failed to stream %s (are tar and zstd installed there?): %w: %s", src, err, messages)
+	}
+	if extractErr != nil {
+		return extractErr
+	}
+	if received < len(want) {
+		return fmt.Errorf("pulled %d of %d files from %s; rerun to resume: %s", received, len(want), src, messages)
+	}
+
+	// GNU tar exits 1 when files changed while it read them, as a running
+	// fuzzer's corpus does; every file still arrived
+	switch {
+	case tarStatus == 1:
+		fmt.Printf("⚠ Files in %s changed while they were pulled: %s\n", src, messages)
+	case tarStatus > 1:
+		return fmt.Errorf("failed to stream %s: tar exited with status %d: %s", src, tarStatus, messages)
+	}
+	return nil
+}
+
+// splitTarStatus separates the remote tar's exit status, 0 when it
+// succeeded, from the other messages on stderr.
+func splitTarStatus(stderr string) (int, string) {
+	status := 0
+	var messages []string
+	for _, line := range strings.Split(strings.TrimSpace(stderr), "\n") {
+		if code, ok := strings.CutPrefix(line, tarStatusMarker+" "); ok {
+			status, _ = strconv.Atoi(strings.TrimSpace(code))
+			continue
+		}
+		if line != "" {
+			messages = append(messages, line)
+		}
+	}
+	return status, strings.Join(messages, "; ")
+}
+
+// listRemoteFiles lists the regular files below dir with their size and
+// modification time.
+func (s *SSHClient) listRemoteFiles(dir string) ([]remoteFile, error) {
+	output, err := s.RunCommand(fmt.Sprintf("cd %s && find . -type f -printf '%%s %%T@ %%P\\0'", shellQuote(dir)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", dir, err)
+	}
+
+	var files []remoteFile
+	for _, entry := range strings.Split(output, "\x00") {
+		fields := strings.SplitN(entry, " ", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		size, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		secs, _, _ := strings.Cut(fields[1], ".")
+		mtime, _ := strconv.ParseInt(secs, 10, 64)
+		files = append(files, remoteFile{name: fields[2], size: size, mtime: mtime})
+	}
+	return files, nil
+}
+
+// extractTar unpacks regular files and directories from r into dst and
+// returns the number of files written. Other entry types are skipped.
+func extractTar(r io.Reader, dst string, progress *progressBar) (int, error) {
+	received := 0
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return received, nil
+		}
+		if err != nil {
+			return received, fmt.Errorf("failed to read stream: %w", err)
+		}
+
+		name := path.Clean(hdr.Name)
+		if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return received, fmt.Errorf("refusing to extract %q outside %s", hdr.Name, dst)
+		}
+		target := filepath.Join(dst, filepath.FromSlash(name))
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return received, err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return received, err
+			}
+			if err := writeFile(target, tr, hdr, progress); err != nil {
+				return received, err
+			}
+			received++
+			progress.fileDone()
+		}
+	}
+}
+
+// writeFile writes one archive entry under a temporary name and renames it
+// into place once complete.
+func writeFile(target string, r io.Reader, hdr *tar.Header, progress *progressBar) error {
+	part := target + partSuffix
+	f, err := os.OpenFile(part, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(hdr.Mode).Perm()|0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, io.TeeReader(r, progress)); err != nil {
+		f.Close()
+		os.Remove(part)
+		return fmt.Errorf("failed to write %s: %w", target, err)
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Chtimes(part, hdr.ModTime, hdr.ModTime); err != nil {
+		return err
+	}
+	return os.Rename(part, target)
+}
+
+// selected applies --include and --exclude globs to a relative path. Excludes
+// win; with no includes, everything else is selected.
+func selected(name string, include, exclude []string) bool {
+	for _, p := range exclude {
+		if globMatch(p, name) {
+			return false
+		}
+	}
+	if len(include) == 0 {
+		return true
+	}
+	for _, p := range include {
+		if globMatch(p, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// globMatch matches a gitignore-style pattern against a file path the way the
+// rsync filters of a regular pull do: a pattern without an inner slash matches
+// a name at any depth, one with a slash is anchored to the root, ** spans
+// directories, and matching a directory matches everything below it.
+func globMatch(pattern, name string) bool {
+	dirOnly := strings.HasSuffix(pattern, "/")
+	trimmed := strings.TrimSuffix(pattern, "/")
+	anchored := strings.Contains(trimmed, "/")
+	trimmed = strings.TrimPrefix(trimmed, "/")
+	patternSegs := strings.Split(trimmed, "/")
+
+	segs := strings.Split(name, "/")
+	for i := 1; i <= len(segs); i++ {
+		if dirOnly && i == len(segs) {
+			break
+		}
+		if anchored {
+			if matchSegments(patternSegs, segs[:i]) {
+				return true
+			}
+		} else if ok, _ := path.Match(trimmed, segs[i-1]); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func matchSegments(pattern, segs []string) bool {
+	if len(pattern) == 0 {
+		return len(segs) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(segs); i++ {
+			if matchSegments(pattern[1:], segs[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(segs) == 0 {
+		return false
+	}
+	ok, _ := path.Match(pattern[0], segs[0])
+	return ok && matchSegments(pattern[1:], segs[1:])
+}
+
+// progressBar renders transfer progress on one terminal line. Off a terminal
+// it only prints the final line.
+type progressBar struct {
+	total, done int64
+	files, n    int
+	start, last time.Time
+	live        bool
+}
+
+func newProgressBar(total int64, files int) *progressBar {
+	info, err := os.Stdout.Stat()
+	live := err == nil && info.Mode()&os.ModeCharDevice != 0
+	return &progressBar{total: total, files: files, start: time.Now(), live: live}
+}
+
+func (p *progressBar) Write(b []byte) (int, error) {
+	p.done += int64(len(b))
+	if p.live && time.Since(p.last) > 100*time.Millisecond {
+		p.render()
+	}
+	return len(b), nil
+}
+
+func (p *progressBar) fileDone() {
+	p.n++
+}
+
+func (p *progressBar) render() {
+	p.last = time.Now()
+	const width = 30
+	percent := 100
+	if p.total > 0 && p.done < p.total {
+		percent = int(p.done * 100 / p.total)
+	}
+	filled := percent * width / 100
+	rate := float64(p.done) / time.Since(p.start).Seconds() / 1024
+	fmt.Printf("\r[%s%s] %3d%% %s/%s  %d/%d files  %s/s ",
+		strings.Repeat("#", filled), strings.Repeat(".", width-filled), percent,
+		formatKB(p.done/1024), formatKB(p.total/1024), p.n, p.files, formatKB(int64(rate)))
+}
+
+func (p *progressBar) finish() {
+	p.render()
+	fmt.Printf("in %s\n", time.Since(p.start).Round(time.Second))
+}
@@ -0,0 +1,373 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	// ignoreFile holds sync exclusions in gitignore syntax at the project root.
+	ignoreFile = ".osirisignore"
+
+	// defaultDeleteThreshold is how many remote deletions a sync may make
+	// without confirmation when the config sets no threshold.
+	defaultDeleteThreshold = 50
+)
+
+var (
+	syncDryRun bool
+	syncYes    bool
+	runPreview bool
+)
+
+// SyncOptions come from the `sync:` config block.
+type SyncOptions struct {
+	// Exclude lists extra patterns that are never synced
+	Exclude []string `mapstructure:"exclude"`
+
+	// Protect lists remote paths that --delete must never remove
+	Protect []string `mapstructure:"protect"`
+
+	// GitIgnore also applies the project's .gitignore files
+	GitIgnore bool `mapstructure:"gitignore"`
+
+	// DeleteThreshold is the number of remote deletions above which a sync
+	// asks for confirmation; negative disables the check
+	DeleteThreshold *int `mapstructure:"delete-threshold"`
+}
+
+func loadSyncOptions() (SyncOptions, error) {
+	var opts SyncOptions
+	if err := viper.UnmarshalKey("sync", &opts); err != nil {
+		return opts, fmt.Errorf("invalid sync config: %w", err)
+	}
+	return opts, nil
+}
+
+func (o SyncOptions) deleteThreshold() int {
+	if o.DeleteThreshold == nil {
+		return defaultDeleteThreshold
+	}
+	return *o.DeleteThreshold
+}
+
+// syncFilters returns the rsync filter rules for syncing the tree at root, in
+// rsync's first-match-wins order: protect rules, the built-in excludes, config
+// excludes, .osirisignore, then .gitignore when enabled. Nested .gitignore
+// files, e.g. of submodules under lib/, are read by rsync as it walks the
+// tree; they only exclude, as rsync has no ! negation in merge files. The
+// remote results directory is always protected. Excluded paths are also left
+// alone on the remote, since rsync only deletes what it transfers.
+func syncFilters(root string, opts SyncOptions) ([]string, error) {
+	var rules []string
+
+	protect := opts.Protect
+	if rel, err := remoteResultsDir(); err == nil {
+		protect = append(protect, "/"+rel+"/")
+	}
+	for _, p := range protect {
+		rules = append(rules, "P "+rsyncPattern(p))
+	}
+
+	for _, name := range syncExcludes {
+		rules = append(rules, "- "+name)
+	}
+	for _, p := range opts.Exclude {
+		rules = append(rules, "- "+rsyncPattern(p))
+	}
+
+	files := []string{ignoreFile}
+	if opts.GitIgnore {
+		files = append(files, ".gitignore")
+	}
+	for _, name := range files {
+		fileRules, err := ignoreRules(filepath.Join(root, name))
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, fileRules...)
+	}
+	if opts.GitIgnore {
+		rules = append(rules, ":- .gitignore")
+	}
+	return rules, nil
+}
+
+// ignoreRules converts a gitignore-syntax file into rsync filter rules. The
+// last matching gitignore line wins while rsync uses the first matching rule,
+// so the order is reversed. A missing file has no rules.
+func ignoreRules(file string) ([]string, error) {
+	f, err := os.Open(file)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", file, err)
+	}
+	defer f.Close()
+
+	var rules []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		rule := "- "
+		if strings.HasPrefix(line, "!") {
+			rule, line = "+ ", line[1:]
+		} else if strings.HasPrefix(line, `\#`) || strings.HasPrefix(line, `\!`) {
+			line = line[1:]
+		}
+		if line == "" {
+			continue
+		}
+		rules = append([]string{rule + rsyncPattern(line)}, rules...)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", file, err)
+	}
+	return rules, nil
+}
+
+// rsyncPattern translates a gitignore pattern to rsync. Both match a pattern
+// without a slash at any depth, but gitignore anchors a pattern with an inner
+// slash to the root, where rsync would match it at any depth.
+func rsyncPattern(p string) string {
+	inner := strings.TrimSuffix(p, "/")
+	if strings.HasPrefix(p, "/") || strings.HasPrefix(inner, "**/") || !strings.Contains(inner, "/") {
+		return p
+	}
+	return "/" + p
+}
+
+// projectRelative returns p as a clean slash path relative to the project
+// root, or false when p points outside it.
+func projectRelative(p string) (string, bool) {
+	if p == "" || filepath.IsAbs(p) {
+		return "", false
+	}
+	rel := path.Clean(filepath.ToSlash(p))
+	if rel == "." || rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", false
+	}
+	return rel, true
+}
+
+// rsyncPushArgs returns the rsync arguments that mirror the tree at root to
+// dest with --delete, applying the sync filters. extra flags go first.
+func rsyncPushArgs(root, dest string, opts SyncOptions, extra ...string) ([]string, error) {
+	filters, err := syncFilters(root, opts)
+	if err != nil {
+		return nil, err
+	}
+	args := append([]string{"-az", "--delete"}, extra...)
+	for _, rule := range filters {
+		args = append(args, "--filter="+rule)
+	}
+	return append(args, "-e", "ssh -F "+os.Getenv("HOME")+"/.ssh/config", root+"/", dest), nil
+}
+
+// syncSummary counts what a sync would change, overall and per top-level path.
+type syncSummary struct {
+	total  syncCounts
+	groups map[string]*syncCounts
+}
+
+type syncCounts struct {
+	added, changed, deleted int
+	bytes                   int64
+}
+
+// previewSync runs rsync in dry-run mode and summarizes its itemized changes.
+func previewSync(root, dest string, opts SyncOptions) (syncSummary, error) {
+	args, err := rsyncPushArgs(root, dest, opts, "--dry-run", "--out-format=%i|%l|%n")
+	if err != nil {
+		return syncSummary{}, err
+	}
+	var stderr bytes.Buffer
+	cmd := exec.Command("rsync", args...)
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return syncSummary{}, fmt.Errorf("failed to preview sync: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return parseItemized(string(output)), nil
+}
+
+// parseItemized reads rsync --itemize-changes lines in the form
+// "%i|%l|%n". Directories are not counted, only their contents.
+func parseItemized(output string) syncSummary {
+	summary := syncSummary{groups: make(map[string]*syncCounts)}
+	for _, line := range strings.Split(output, "\n") {
+		parts := strings.SplitN(line, "|", 3)
+		if len(parts) != 3 || strings.HasSuffix(parts[2], "/") {
+			continue
+		}
+		item, name := strings.TrimSpace(parts[0]), parts[2]
+		size, _ := strconv.ParseInt(parts[1], 10, 64)
+
+		group, _, found := strings.Cut(name, "/")
+		if !found {
+			group = "."
+		}
+		counts := summary.groups[group]
+		if counts == nil {
+			counts = &syncCounts{}
+			summary.groups[group] = counts
+		}
+
+		switch {
+		case strings.HasPrefix(item, "*deleting"):
+			counts.deleted++
+			summary.total.deleted++
+		case len(item) > 2 && item[2] == '+':
+			counts.added++
+			counts.bytes += size
+			summary.total.added++
+			summary.total.bytes += size
+		case len(item) > 1 && (item[0] == '<' || item[0] == 'c' || item[0] == '.'):
+			counts.changed++
+			summary.total.changed++
+			if item[0] == '<' {
+				counts.bytes += size
+				summary.total.bytes += size
+			}
+		}
+	}
+	return summary
+}
+
+func (s syncSummary) print(out io.Writer) {
+	t := s.total
+	if t.added+t.changed+t.deleted == 0 {
+		fmt.Fprintln(out, "✓ Remote is up to date")
+		return
+	}
+	fmt.Fprintf(out, "Sync: %d added, %d changed, %d deleted (%s to transfer)\n", t.added, t.changed, t.deleted, formatKB(t.bytes/1024))
+
+	var groups []string
+	for g := range s.groups {
+		groups = append(groups, g)
+	}
+	sort.Strings(groups)
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "  PATH\tADDED\tCHANGED\tDELETED\tSIZE")
+	for _, g := range groups {
+		c := s.groups[g]
+		fmt.Fprintf(w, "  %s\t%d\t%d\t%d\t%s\n", g, c.added, c.changed, c.deleted, formatKB(c.bytes/1024))
+	}
+	w.Flush()
+}
+
+// confirmDeletes asks before a sync deletes more remote files than the
+// threshold allows. --yes skips the question; without a terminal to ask on,
+// the sync is refused.
+func confirmDeletes(summary syncSummary, opts SyncOptions, interactive bool) error {
+	threshold := opts.deleteThreshold()
+	deleted := summary.total.deleted
+	if threshold < 0 || deleted <= threshold || syncYes {
+		return nil
+	}
+
+	if info, err := os.Stdin.Stat(); !interactive || err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return fmt.Errorf("sync would delete %d remote files (threshold %d); check with --preview and pass --yes to proceed", deleted, threshold)
+	}
+	answer := prompt(bufio.NewReader(os.Stdin), fmt.Sprintf("⚠ Sync will delete %d remote files. Continue? [y/N]", deleted), "")
+	if answer != "y" && answer != "Y" && answer != "yes" {
+		return fmt.Errorf("sync cancelled")
+	}
+	return nil
+}
+
+// pushTree mirrors root to dest: it previews the changes, prints the summary,
+// checks the deletion threshold, then syncs.
+func pushTree(root, dest string, opts SyncOptions, out io.Writer, interactive bool) error {
+	summary, err := previewSync(root, dest, opts)
+	if err != nil {
+		return err
+	}
+	summary.print(out)
+	if err := confirmDeletes(summary, opts, interactive); err != nil {
+		return err
+	}
+
+	args, err := rsyncPushArgs(root, dest, opts)
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command("rsync", args...)
+	cmd.Stdout = out
+	cmd.Stderr = out
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to sync files: %w", err)
+	}
+	return nil
+}
+
+func newSyncCommand() *cobra.Command {
+	syncCmd := &cobra.Command{
+		Use:   "sync",
+		Short: "Push the project to the remote without running anything",
+		Args:  cobra.NoArgs,
+		RunE:  syncCommand,
+	}
+	syncCmd.Flags().BoolVarP(&syncDryRun, "dry-run", "n", false, "Only show what would change")
+	syncCmd.Flags().BoolVarP(&syncYes, "yes", "y", false, "Delete remote files beyond the delete threshold without asking")
+	syncCmd.Flags().StringVar(&runRef, "ref", "", "Sync a clean checkout of this commit or branch, with submodules, instead of the working tree")
+	return syncCmd
+}
+
+func syncCommand(cmd *cobra.Command, args []string) error {
+	opts, err := loadSyncOptions()
+	if err != nil {
+		return err
+	}
+
+	source := "."
+	if runRef != "" {
+		root, dir, info, err := exportRef(runRef)
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(root)
+		source = dir
+		fmt.Printf("Using %s at %s\n", runRef, info.Commit[:12])
+	}
+
+	h := currentHost()
+	client, err := connect(h)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	if _, err := client.RunCommand(fmt.Sprintf("mkdir -p %s", h.RemotePath)); err != nil {
+		return fmt.Errorf("failed to create remote directory: %w", err)
+	}
+
+	dest := client.alias + ":" + h.RemotePath
+	if syncDryRun {
+		summary, err := previewSync(source, dest, opts)
+		if err != nil {
+			return err
+		}
+		summary.print(os.Stdout)
+		return nil
+	}
+	return pushTree(source, dest, opts, os.Stdout, true)
+}
@@ -0,0 +1,209 @@
+package cmd
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// defaultKeepWorkspaces is how many finished job workspaces jobs prune keeps
+// when neither the config nor its flags set a retention policy.
+const defaultKeepWorkspaces = 20
+
+var (
+	sharedCorpusFlags []string
+	pruneKeep         int
+	pruneMaxAge       time.Duration
+	pruneUnpulled     bool
+)
+
+// WorkspaceOptions come from the `workspace:` config block. Every job runs in
+// its own snapshot of the synced tree; SharedCorpus directories are instead
+// mounted from a persistent location shared by all jobs.
+type WorkspaceOptions struct {
+	SharedCorpus []string      `mapstructure:"shared-corpus"`
+	Keep         *int          `mapstructure:"keep"`
+	MaxAge       time.Duration `mapstructure:"max-age"`
+}
+
+// loadWorkspaceOptions merges the config block with --shared-corpus flags.
+func loadWorkspaceOptions() (WorkspaceOptions, error) {
+	var opts WorkspaceOptions
+	if err := viper.UnmarshalKey("workspace", &opts); err != nil {
+		return opts, fmt.Errorf("invalid workspace config: %w", err)
+	}
+	opts.SharedCorpus = append(opts.SharedCorpus, sharedCorpusFlags...)
+
+	for i, dir := range opts.SharedCorpus {
+		clean := path.Clean(strings.TrimPrefix(dir, "./"))
+		if path.IsAbs(clean) || clean == "." || clean == ".." || strings.HasPrefix(clean, "../") || clean == stateDir || strings.HasPrefix(clean, stateDir+"/") {
+			return opts, fmt.Errorf("invalid shared corpus %q: expected a directory inside the project", dir)
+		}
+		opts.SharedCorpus[i] = clean
+	}
+	if opts.Keep != nil && *opts.Keep < 0 {
+		return opts, fmt.Errorf("workspace keep must not be negative")
+	}
+	return opts, nil
+}
+
+// retains reports whether the config sets a retention policy. Without one,
+// run never prunes.
+func (o WorkspaceOptions) retains() bool {
+	return o.Keep != nil || o.MaxAge > 0
+}
+
+func workspaceDir(remotePath, id string) string {
+	return path.Join(jobDir(remotePath, id), "workspace")
+}
+
+func sharedCorpusDir(remotePath, dir string) string {
+	return path.Join(remotePath, stateDir, "corpus", dir)
+}
+
+// snapshotScript renders the commands that give a job its own copy of the
+// synced tree. Each top-level entry is cloned copy-on-write where the
+// filesystem supports it, else copied. Hardlinks would be cheaper but share
+// inodes, so a job rewriting a file in place (forge's cache, a corpus file
+// opened with O_TRUNC) would change it for the synced tree and other jobs.
+func snapshotScript(remotePath string, job Job) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "src=%s; ws=%s\n", shellQuote(remotePath), shellQuote(job.Workspace))
+	b.WriteString(`mkdir -p "$ws" || exit 1
+for f in "$src"/* "$src"/.[!.]* "$src"/..?*; do
+  [ -e "$f" ] || [ -L "$f" ] || continue
+  name=${f##*/}
+  [ "$name" = ` + stateDir + ` ] && continue
+  cp -a --reflink=always "$f" "$ws/" 2>/dev/null ||
+    { rm -rf "$ws/$name" && cp -a "$f" "$ws/"; } || exit 1
+done
+`)
+
+	// Shared corpora start from the synced tree's copy the first time
+	for _, dir := range job.SharedCorpus {
+		fmt.Fprintf(&b, "shared=%s\n", shellQuote(sharedCorpusDir(remotePath, dir)))
+		fmt.Fprintf(&b, "[ -d \"$shared\" ] || { mkdir -p \"$shared\" && { [ ! -d \"$src\"/%s ] || cp -a \"$src\"/%s/. \"$shared\"/; }; } || exit 1\n", shellQuote(dir), shellQuote(dir))
+		fmt.Fprintf(&b, "rm -rf \"$ws\"/%s\n", shellQuote(dir))
+	}
+	return b.String()
+}
+
+// mountArgs renders the docker run mounts for a job's workspace and shared corpora.
+func (j Job) mountArgs(remotePath string) string {
+	args := []string{"-v", shellQuote(j.Workspace + ":/app")}
+	for _, dir := range j.SharedCorpus {
+		args = append(args, "-v", shellQuote(sharedCorpusDir(remotePath, dir)+":"+path.Join("/app", dir)))
+	}
+	return strings.Join(args, " ")
+}
+
+func newJobsPruneCommand() *cobra.Command {
+	pruneCmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Delete the workspaces of finished jobs, keeping their records and logs",
+		Args:  cobra.NoArgs,
+		RunE:  jobsPruneCommand,
+	}
+	pruneCmd.Flags().IntVar(&pruneKeep, "keep", -1, "Number of most recent finished workspaces to keep (default: workspace.keep from config, else 20)")
+	pruneCmd.Flags().DurationVar(&pruneMaxAge, "max-age", 0, "Also delete workspaces of jobs that finished longer ago than this")
+	pruneCmd.Flags().BoolVar(&pruneUnpulled, "unpulled", false, "Also delete workspaces of jobs whose results were never pulled")
+	return pruneCmd
+}
+
+func jobsPruneCommand(cmd *cobra.Command, args []string) error {
+	opts, err := loadWorkspaceOptions()
+	if err != nil {
+		return err
+	}
+	if pruneKeep >= 0 {
+		opts.Keep = &pruneKeep
+	}
+	if pruneMaxAge > 0 {
+		opts.MaxAge = pruneMaxAge
+	}
+	if !opts.retains() {
+		keep := defaultKeepWorkspaces
+		opts.Keep = &keep
+	}
+
+	client, err := connect(currentHost())
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	pruned, unpulled, err := client.PruneWorkspaces(remotePath, opts, pruneUnpulled)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Pruned %d workspace(s)\n", pruned)
+	if unpulled > 0 {
+		fmt.Printf("⚠ Kept %d workspace(s) whose results were never pulled; pull them or use --unpulled\n", unpulled)
+	}
+	return nil
+}
+
+// PruneWorkspaces applies the retention policy: the workspaces of finished
+// jobs beyond the most recent keep, or older than max-age, are deleted. Job
+// records and output logs stay. Workspaces of jobs that were never pulled
+// hold the only copy of their results and are kept unless unpulled is set.
+// It returns the number of workspaces deleted and the number kept because
+// they were not pulled.
+func (s *SSHClient) PruneWorkspaces(remotePath string, opts WorkspaceOptions, unpulled bool) (int, int, error) {
+	jobs, err := s.ListJobs(remotePath)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// Cancelled jobs never started, so their age counts from creation and
+	// they have no results to pull
+	type finishedJob struct {
+		workspace, image string
+		at               time.Time
+		pulled           bool
+	}
+	var finished []finishedJob
+	for _, j := range jobs {
+		if j.Workspace == "" {
+			continue
+		}
+		switch j.Status {
+		case "exited":
+			if t, err := time.Parse(time.RFC3339, j.Finished); err == nil {
+				finished = append(finished, finishedJob{j.Workspace, j.Image, t, j.Pulled != ""})
+			}
+		case "cancelled":
+			finished = append(finished, finishedJob{j.Workspace, j.Image, j.Created, true})
+		}
+	}
+	sort.SliceStable(finished, func(a, b int) bool { return finished[a].at.After(finished[b].at) })
+
+	// Containers run as root by default, so files they wrote may need the
+	// job's image to delete
+	var script []string
+	for i, j := range finished {
+		if (opts.Keep == nil || i < *opts.Keep) && (opts.MaxAge == 0 || time.Since(j.at) <= opts.MaxAge) {
+			continue
+		}
+		if !j.pulled && !unpulled {
+			script = append(script, fmt.Sprintf(`[ -d %s ] && echo kept`, shellQuote(j.workspace)))
+			continue
+		}
+		script = append(script, fmt.Sprintf(`ws=%s; [ -d "$ws" ] && { rm -rf "$ws" 2>/dev/null || docker run --rm -v "$ws":/ws --entrypoint sh %s -c 'rm -rf /ws/* /ws/.[!.]* /ws/..?*' >/dev/null 2>&1; rm -rf "$ws"; } && echo removed`,
+			shellQuote(j.workspace), shellQuote(j.image)))
+	}
+	if len(script) == 0 {
+		return 0, 0, nil
+	}
+
+	output, err := s.RunCommand(strings.Join(script, "\n") + "\ntrue")
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to prune workspaces: %w", err)
+	}
+	return strings.Count(output, "removed"), strings.Count(output, "kept"), nil
+}
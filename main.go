@@ -1,7 +1,13 @@
 package main
 
-import "github.com/Enigma-Dark/osiris-lite/cmd"
+import (
+	"os"
+
+	"github.com/Enigma-Dark/osiris-lite/cmd"
+)
 
 func main() {
-	cmd.Execute()
+	if err := cmd.Execute(); err != nil {
+		os.Exit(1)
+	}
 }